@@ -2,10 +2,17 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"slices"
+	"strings"
+	"time"
 
+	cel "cel.dev/cel-go/cel"
 	"gopkg.in/yaml.v3"
+	kubelabels "k8s.io/apimachinery/pkg/labels"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	kubejsonpath "k8s.io/client-go/util/jsonpath"
 )
 
 // Interval specficiation.
@@ -14,6 +21,51 @@ const (
 	defaultSeconds = 60
 )
 
+// Number of targets scanned concurrently.
+const (
+	minWorkers     = 1
+	defaultWorkers = 4
+)
+
+// Retry defaults.
+const (
+	defaultRetryAttempts                = 3
+	defaultRetryInitialBackoff          = time.Second
+	defaultRetryMaxBackoff              = 20 * time.Second
+	defaultExitAfterConsecutiveFailures = 3
+)
+
+// Maximum number of buffered datums by default.
+const defaultBufferSize = 10000
+
+// Listen address of the HTTP server by default.
+const defaultServerAddress = ":8080"
+
+// Number of intervals a due round may be overdue before the liveness probe
+// fails by default.
+const defaultServerLivenessRounds = 3
+
+// Allowed output types.
+const (
+	outputTypeCloudWatch = "CloudWatch"
+	outputTypeEMF        = "EMF"
+	outputTypeOTLP       = "OTLP"
+)
+
+// Allowed protocols of OTLP outputs. Same values as used by the environment
+// variable "OTEL_EXPORTER_OTLP_PROTOCOL".
+const (
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http/protobuf"
+)
+
+// Allowed failure policies of outputs. Failures of fatal outputs count
+// towards giving up, failures of best-effort outputs are only logged.
+const (
+	failurePolicyFatal      = "Fatal"
+	failurePolicyBestEffort = "BestEffort"
+)
+
 // Allowed logging levels.
 const (
 	logLevelDebug = "debug"
@@ -32,23 +84,103 @@ type logging struct {
 	Format string `yaml:"format"`
 }
 
+// shutdown configures what happens with the metric when the program is
+// stopped by a signal.
+type shutdown struct {
+	Publish bool    `yaml:"publish"`
+	Value   float64 `yaml:"value"`
+}
+
+// retry configures retries of failed metric updates and when to give up.
+type retry struct {
+	Attempts                     int           `yaml:"attempts"`
+	InitialBackoff               time.Duration `yaml:"initialBackoff"`
+	MaxBackoff                   time.Duration `yaml:"maxBackoff"`
+	ExitAfterConsecutiveFailures int           `yaml:"exitAfterConsecutiveFailures"`
+}
+
+// buffer configures buffering of datums that could not be sent.
+type buffer struct {
+	Enabled bool   `yaml:"enabled"`
+	Size    int    `yaml:"size"`
+	Path    string `yaml:"path"`
+}
+
+// server configures the HTTP server that exposes Prometheus metrics and the
+// liveness and readiness probes.
+type server struct {
+	Enabled        bool   `yaml:"enabled"`
+	Address        string `yaml:"address"`
+	LivenessRounds int    `yaml:"livenessRounds"`
+}
+
+// output configures a destination metrics are sent to. For example
+// CloudWatch directly, embedded metric format (EMF) lines to stdout or a
+// file, or an OpenTelemetry collector via OTLP.
+type output struct {
+	Type          string `yaml:"type"`
+	Path          string `yaml:"path"`
+	Endpoint      string `yaml:"endpoint"`
+	Protocol      string `yaml:"protocol"`
+	FailurePolicy string `yaml:"failurePolicy"`
+}
+
 // dimension is a single CloudWatch metric dimension.
 type dimension struct {
 	Name  string `yaml:"name"`
 	Value string `yaml:"value"`
 }
 
+// Maximum number of dimensions CloudWatch accepts per metric.
+const maxDimensions = 30
+
+// Dimension names added to per-target metrics and replica counts.
+const (
+	dimensionTargetKind      = "TargetKind"
+	dimensionTargetNamespace = "TargetNamespace"
+	dimensionTargetName      = "TargetName"
+)
+
+// Metric names used for replica counts.
+const (
+	metricNameReadyReplicas   = "ReadyReplicas"
+	metricNameDesiredReplicas = "DesiredReplicas"
+	metricNameReadyRatio      = "ReadyRatio"
+)
+
+// Metric name used for the number of consecutive failed updates preceding a
+// successful one.
+const metricNameFailedUpdates = "FailedUpdates"
+
+// Allowed storage resolutions in seconds. High resolution is only meaningful
+// if metrics are published more often than once per minute.
+const (
+	storageResolutionHigh     = 1
+	storageResolutionStandard = 60
+)
+
 // metric configures the CloudWatch metric.
 type metric struct {
-	Namespace  string      `yaml:"namespace"`
-	Name       string      `yaml:"name"`
-	Dimensions []dimension `yaml:"dimensions"`
+	Namespace         string      `yaml:"namespace"`
+	Name              string      `yaml:"name"`
+	Dimensions        []dimension `yaml:"dimensions"`
+	PerTarget         bool        `yaml:"perTarget"`
+	ReplicaCounts     bool        `yaml:"replicaCounts"`
+	StorageResolution int32       `yaml:"storageResolution"`
 }
 
 // Allowed target modes.
 const (
 	modeAllOfThem  = "AllOfThem"
 	modeAtLeastOne = "AtLeastOne"
+	modePercentage = "Percentage"
+	modeMinReady   = "MinReady"
+)
+
+// Allowed range for the threshold of the "Percentage" mode.
+const (
+	minThreshold = 1
+	maxThreshold = 100
 )
 
 // Allowed target kinds.
@@ -56,24 +188,76 @@ const (
 	kindDaemonSet   = "DaemonSet"
 	kindDeployment  = "Deployment"
 	kindStatefulSet = "StatefulSet"
+	kindPod         = "Pod"
+	kindJob         = "Job"
+	kindCronJob     = "CronJob"
+	kindNode        = "Node"
+)
+
+// Allowed behaviors if a target selects no objects.
+const (
+	noMatchNotReady = "NotReady"
+	noMatchReady    = "Ready"
 )
 
+// jsonPath contains JSONPath templates to extract replica counts from custom
+// resources. If desired is empty, ready must extract a boolean.
+type jsonPath struct {
+	Ready   string `yaml:"ready"`
+	Desired string `yaml:"desired"`
+}
+
 // target is a single Kubernetes target to scan.
 type target struct {
-	Kind      string `yaml:"kind"`
-	Namespace string `yaml:"namespace"`
-	Name      string `yaml:"name"`
-	Mode      string `yaml:"mode"`
+	Kind              string        `yaml:"kind"`
+	Namespace         string        `yaml:"namespace"`
+	NamespaceSelector string        `yaml:"namespaceSelector"`
+	Name              string        `yaml:"name"`
+	LabelSelector     string        `yaml:"labelSelector"`
+	Mode              string        `yaml:"mode"`
+	Threshold         int           `yaml:"threshold"`
+	MinReady          int           `yaml:"minReady"`
+	OnNoMatch         string        `yaml:"onNoMatch"`
+	CheckConditions   bool          `yaml:"checkConditions"`
+	MaxAge            time.Duration `yaml:"maxAge"`
+	APIVersion        string        `yaml:"apiVersion"`
+	Resource          string        `yaml:"resource"`
+	Condition         string        `yaml:"condition"`
+	Expression        string        `yaml:"expression"`
+	JSONPath          jsonPath      `yaml:"jsonPath"`
+
+	// Compiled expression. Set during processing if expression is not empty.
+	program cel.Program
+}
+
+// Name of the single check built from top-level metric and targets.
+const defaultCheckName = "default"
+
+// check is a metric together with the targets it is based on. Checks are
+// scanned in the same round and published together.
+type check struct {
+	Name    string   `yaml:"name"`
+	Metric  metric   `yaml:"metric"`
+	Targets []target `yaml:"targets"`
 }
 
 // config is the central configuration.
 // Use NewConfig to create a new config.
 type config struct {
-	DryRun  bool     `yaml:"dryRun"`
-	Seconds int      `yaml:"seconds"`
-	Metric  metric   `yaml:"metric"`
-	Targets []target `yaml:"targets"`
-	Logging logging  `yaml:"logging"`
+	DryRun          bool     `yaml:"dryRun"`
+	Seconds         int      `yaml:"seconds"`
+	Workers         int      `yaml:"workers"`
+	Informers       bool     `yaml:"informers"`
+	PublishOnChange bool     `yaml:"publishOnChange"`
+	Shutdown        shutdown `yaml:"shutdown"`
+	Retry           retry    `yaml:"retry"`
+	Buffer          buffer   `yaml:"buffer"`
+	Server          server   `yaml:"server"`
+	Outputs         []output `yaml:"outputs"`
+	Metric          metric   `yaml:"metric"`
+	Targets         []target `yaml:"targets"`
+	Checks          []check  `yaml:"checks"`
+	Logging         logging  `yaml:"logging"`
 }
 
 // newConfig reads and processes the configuration.
@@ -107,12 +291,62 @@ func processConfig(config config) (config, error) {
 		config.Seconds = defaultSeconds
 	}
 
-	if err := validateMetric(config.Metric); err != nil {
-		return config, fmt.Errorf("validate metric config: %v", err)
+	if config.Workers < minWorkers {
+		config.Workers = defaultWorkers
+	}
+
+	retry, err := processRetry(config.Retry)
+	if err != nil {
+		return config, fmt.Errorf("process retry config: %v", err)
+	}
+
+	config.Retry = retry
+
+	if config.Buffer.Size < 1 {
+		config.Buffer.Size = defaultBufferSize
+	}
+
+	if config.Buffer.Path != "" && !config.Buffer.Enabled {
+		return config, fmt.Errorf("buffer.path requires buffer.enabled")
+	}
+
+	if config.Server.Address != "" && !config.Server.Enabled {
+		return config, fmt.Errorf("server.address requires server.enabled")
 	}
 
-	if err := validateTargets(config.Targets); err != nil {
-		return config, fmt.Errorf("validate targets config: %v", err)
+	if config.Server.Address == "" {
+		config.Server.Address = defaultServerAddress
+	}
+
+	if config.Server.LivenessRounds < 1 {
+		config.Server.LivenessRounds = defaultServerLivenessRounds
+	}
+
+	outputs, err := processOutputs(config.Outputs)
+	if err != nil {
+		return config, fmt.Errorf("process outputs config: %v", err)
+	}
+
+	config.Outputs = outputs
+
+	if config.PublishOnChange && !config.Informers {
+		return config, fmt.Errorf("publishOnChange requires informers")
+	}
+
+	checksBuilt := len(config.Checks) == 0
+
+	checks, err := processChecks(config)
+	if err != nil {
+		return config, err
+	}
+
+	config.Checks = checks
+
+	// Keep top-level metric and targets in sync with the check built from
+	// them, so that both always reflect the processed configuration.
+	if checksBuilt {
+		config.Metric = config.Checks[0].Metric
+		config.Targets = config.Checks[0].Targets
 	}
 
 	allowedLogLevels := []string{logLevelDebug, logLevelInfo}
@@ -138,6 +372,293 @@ func processConfig(config config) (config, error) {
 	return config, nil
 }
 
+// compileTargets compiles the expressions of the given targets. It returns a
+// copy of the targets with the compiled programs set.
+func compileTargets(targets []target) ([]target, error) {
+	targets = slices.Clone(targets)
+
+	for i := range targets {
+		if targets[i].Expression == "" {
+			continue
+		}
+
+		program, err := compileExpression(targets[i].Expression)
+		if err != nil {
+			return nil, fmt.Errorf("target[%v].expression invalid: %v", i, err)
+		}
+
+		targets[i].program = program
+	}
+
+	return targets, nil
+}
+
+// compileExpression parses, type-checks, and compiles the given CEL
+// expression. The fetched object is available as variable "object" and the
+// expression must evaluate to a boolean.
+func compileExpression(expression string) (cel.Program, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create environment: %v", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf(
+			"must evaluate to bool, got %v", ast.OutputType(),
+		)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("create program: %v", err)
+	}
+
+	return program, nil
+}
+
+// processChecks processes and validates the configured checks. If no checks
+// are configured, a single check is built from the top-level metric and
+// targets. Both ways cannot be mixed.
+func processChecks(config config) ([]check, error) {
+	if len(config.Checks) == 0 {
+		processed, err := processCheck(check{
+			Name:    defaultCheckName,
+			Metric:  config.Metric,
+			Targets: config.Targets,
+		}, config.Seconds)
+		if err != nil {
+			return nil, err
+		}
+
+		return []check{processed}, nil
+	}
+
+	if config.Metric.Namespace != "" || config.Metric.Name != "" ||
+		len(config.Targets) > 0 {
+		return nil, fmt.Errorf(
+			"checks not allowed together with metric or targets",
+		)
+	}
+
+	checks := []check{}
+	names := []string{}
+	metricKeys := []string{}
+
+	for i, check := range config.Checks {
+		if check.Name == "" {
+			return nil, fmt.Errorf("missing: checks[%v].name", i)
+		}
+
+		if slices.Contains(names, check.Name) {
+			return nil, fmt.Errorf(
+				"checks[%v].name duplicate: %v", i, check.Name,
+			)
+		}
+
+		names = append(names, check.Name)
+
+		// Checks must not publish to the very same metric.
+		metricKey := fmt.Sprint(
+			check.Metric.Namespace, check.Metric.Name, check.Metric.Dimensions,
+		)
+		if j := slices.Index(metricKeys, metricKey); j >= 0 {
+			return nil, fmt.Errorf(
+				"checks[%v].metric duplicate of checks[%v].metric", i, j,
+			)
+		}
+
+		metricKeys = append(metricKeys, metricKey)
+
+		processed, err := processCheck(check, config.Seconds)
+		if err != nil {
+			return nil, fmt.Errorf("checks[%v]: %v", i, err)
+		}
+
+		checks = append(checks, processed)
+	}
+
+	return checks, nil
+}
+
+// processCheck sets default values for the given check and validates it. The
+// interval in seconds is required to validate the storage resolution.
+func processCheck(check check, seconds int) (check, error) {
+	if check.Metric.StorageResolution == 0 {
+		check.Metric.StorageResolution = storageResolutionStandard
+	}
+
+	if err := validateMetric(check.Metric); err != nil {
+		return check, fmt.Errorf("validate metric config: %v", err)
+	}
+
+	if check.Metric.StorageResolution == storageResolutionHigh &&
+		seconds >= storageResolutionStandard {
+		return check, fmt.Errorf(
+			"metric.storageResolution %v requires seconds below %v, got %v",
+			storageResolutionHigh, storageResolutionStandard, seconds,
+		)
+	}
+
+	if err := validateTargets(check.Targets); err != nil {
+		return check, fmt.Errorf("validate targets config: %v", err)
+	}
+
+	targets, err := compileTargets(check.Targets)
+	if err != nil {
+		return check, fmt.Errorf("compile targets config: %v", err)
+	}
+
+	check.Targets = targets
+
+	return check, nil
+}
+
+// processRetry sets default values for the retry configuration and checks for
+// errors.
+func processRetry(retry retry) (retry, error) {
+	if retry.Attempts < 1 {
+		retry.Attempts = defaultRetryAttempts
+	}
+
+	if retry.InitialBackoff <= 0 {
+		retry.InitialBackoff = defaultRetryInitialBackoff
+	}
+
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = max(defaultRetryMaxBackoff, retry.InitialBackoff)
+	}
+
+	if retry.MaxBackoff < retry.InitialBackoff {
+		return retry, fmt.Errorf(
+			"retry.maxBackoff %v less than retry.initialBackoff %v",
+			retry.MaxBackoff, retry.InitialBackoff,
+		)
+	}
+
+	if retry.ExitAfterConsecutiveFailures < 1 {
+		retry.ExitAfterConsecutiveFailures = defaultExitAfterConsecutiveFailures
+	}
+
+	return retry, nil
+}
+
+// processOutputs sets default values for the outputs configuration and checks
+// for errors. Without outputs, a single fatal CloudWatch output is used. Every
+// output type is allowed only once.
+func processOutputs(outputs []output) ([]output, error) {
+	if len(outputs) == 0 {
+		return []output{{
+			Type:          outputTypeCloudWatch,
+			Path:          "",
+			Endpoint:      "",
+			Protocol:      "",
+			FailurePolicy: failurePolicyFatal,
+		}}, nil
+	}
+
+	outputs = slices.Clone(outputs)
+
+	allowedTypes := []string{
+		outputTypeCloudWatch, outputTypeEMF, outputTypeOTLP,
+	}
+	allowedPolicies := []string{failurePolicyFatal, failurePolicyBestEffort}
+	seenTypes := []string{}
+
+	for i := range outputs {
+		output := &outputs[i]
+
+		if output.Type == "" {
+			return outputs, fmt.Errorf("missing: outputs[%v].type", i)
+		}
+
+		if !slices.Contains(allowedTypes, output.Type) {
+			return outputs, fmt.Errorf(
+				"outputs[%v].type invalid: %v", i, output.Type,
+			)
+		}
+
+		if slices.Contains(seenTypes, output.Type) {
+			return outputs, fmt.Errorf(
+				"outputs[%v].type duplicate: %v", i, output.Type,
+			)
+		}
+
+		seenTypes = append(seenTypes, output.Type)
+
+		if output.Path != "" && output.Type != outputTypeEMF {
+			return outputs, fmt.Errorf(
+				"outputs[%v].path requires type %v", i, outputTypeEMF,
+			)
+		}
+
+		if err := processOTLPOutput(i, output); err != nil {
+			return outputs, err
+		}
+
+		if output.FailurePolicy == "" {
+			output.FailurePolicy = failurePolicyFatal
+		} else if !slices.Contains(allowedPolicies, output.FailurePolicy) {
+			return outputs, fmt.Errorf(
+				"outputs[%v].failurePolicy invalid: %v",
+				i, output.FailurePolicy,
+			)
+		}
+	}
+
+	return outputs, nil
+}
+
+// processOTLPOutput sets default values for the OTLP options of the given
+// output with the given index and checks for errors. The options are only
+// allowed for OTLP outputs.
+func processOTLPOutput(i int, output *output) error {
+	if output.Type != outputTypeOTLP {
+		if output.Endpoint != "" {
+			return fmt.Errorf(
+				"outputs[%v].endpoint requires type %v", i, outputTypeOTLP,
+			)
+		}
+
+		if output.Protocol != "" {
+			return fmt.Errorf(
+				"outputs[%v].protocol requires type %v", i, outputTypeOTLP,
+			)
+		}
+
+		return nil
+	}
+
+	allowedProtocols := []string{otlpProtocolGRPC, otlpProtocolHTTP}
+
+	if output.Protocol == "" {
+		output.Protocol = otlpProtocolGRPC
+	} else if !slices.Contains(allowedProtocols, output.Protocol) {
+		return fmt.Errorf(
+			"outputs[%v].protocol invalid: %v", i, output.Protocol,
+		)
+	}
+
+	if output.Endpoint != "" {
+		endpoint, err := url.Parse(output.Endpoint)
+		if err != nil ||
+			!slices.Contains([]string{"http", "https"}, endpoint.Scheme) ||
+			endpoint.Host == "" {
+			return fmt.Errorf(
+				"outputs[%v].endpoint invalid: %v", i, output.Endpoint,
+			)
+		}
+	}
+
+	return nil
+}
+
 // validateMetric validates the metric configuration.
 func validateMetric(metric metric) error {
 	if metric.Namespace == "" {
@@ -162,6 +683,41 @@ func validateMetric(metric metric) error {
 		}
 	}
 
+	allowedStorageResolutions := []int32{
+		storageResolutionHigh, storageResolutionStandard,
+	}
+
+	if metric.StorageResolution != 0 &&
+		!slices.Contains(allowedStorageResolutions, metric.StorageResolution) {
+		return fmt.Errorf(
+			"metric.storageResolution invalid: %v", metric.StorageResolution,
+		)
+	}
+
+	if metric.PerTarget || metric.ReplicaCounts {
+		targetDimensions := []string{
+			dimensionTargetKind,
+			dimensionTargetNamespace,
+			dimensionTargetName,
+		}
+
+		if len(metric.Dimensions)+len(targetDimensions) > maxDimensions {
+			return fmt.Errorf(
+				"metric.dimensions too many for per-target metrics: %v",
+				len(metric.Dimensions),
+			)
+		}
+
+		for i, dimension := range metric.Dimensions {
+			if slices.Contains(targetDimensions, dimension.Name) {
+				return fmt.Errorf(
+					"metric.dimensions[%v].name reserved: %v",
+					i, dimension.Name,
+				)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -172,12 +728,50 @@ func validateTargets(targets []target) error {
 	}
 
 	for i, target := range targets {
-		if target.Kind == "" {
-			return fmt.Errorf("missing: target[%v].kind", i)
+		if err := validateTargetKind(i, target); err != nil {
+			return err
+		}
+
+		if err := validateTargetSelection(i, target); err != nil {
+			return err
+		}
+
+		if target.Mode == "" {
+			return fmt.Errorf("missing: target[%v].mode", i)
+		}
+
+		if err := validateTargetMode(i, target); err != nil {
+			return err
 		}
 
+		if err := validateTargetKindOptions(i, target); err != nil {
+			return err
+		}
+
+		allowedNoMatches := []string{noMatchNotReady, noMatchReady}
+		if target.OnNoMatch != "" &&
+			!slices.Contains(allowedNoMatches, target.OnNoMatch) {
+			return fmt.Errorf(
+				"target[%v].onNoMatch invalid: %v", i, target.OnNoMatch,
+			)
+		}
+	}
+
+	return nil
+}
+
+// validateTargetKind validates the kind of the target with the given index.
+// Built-in kinds are checked against the list of supported kinds. Custom
+// resources are identified by API version and resource and can have any kind.
+func validateTargetKind(i int, target target) error {
+	if target.Kind == "" {
+		return fmt.Errorf("missing: target[%v].kind", i)
+	}
+
+	if target.APIVersion == "" {
 		allowedTargetKinds := []string{
-			kindDeployment, kindStatefulSet, kindDaemonSet,
+			kindDeployment, kindStatefulSet, kindDaemonSet, kindPod,
+			kindJob, kindCronJob, kindNode,
 		}
 		if !slices.Contains(allowedTargetKinds, target.Kind) {
 			return fmt.Errorf(
@@ -185,25 +779,262 @@ func validateTargets(targets []target) error {
 			)
 		}
 
-		if target.Namespace == "" {
-			return fmt.Errorf("missing: target[%v].namespace", i)
+		if target.Resource != "" {
+			return fmt.Errorf(
+				"target[%v].resource not supported for kind: %v",
+				i, target.Kind,
+			)
 		}
 
-		if target.Name == "" {
-			return fmt.Errorf("missing: target[%v].name", i)
+		if target.Condition != "" {
+			return fmt.Errorf(
+				"target[%v].condition not supported for kind: %v",
+				i, target.Kind,
+			)
 		}
 
-		if target.Mode == "" {
-			return fmt.Errorf("missing: target[%v].mode", i)
+		if target.JSONPath != (jsonPath{Ready: "", Desired: ""}) {
+			return fmt.Errorf(
+				"target[%v].jsonPath not supported for kind: %v",
+				i, target.Kind,
+			)
 		}
 
-		allowedTargetModes := []string{modeAllOfThem, modeAtLeastOne}
-		if !slices.Contains(allowedTargetModes, target.Mode) {
+		return nil
+	}
+
+	if _, err := kubeschema.ParseGroupVersion(target.APIVersion); err != nil {
+		return fmt.Errorf("target[%v].apiVersion invalid: %v", i, err)
+	}
+
+	if target.Resource == "" {
+		return fmt.Errorf("missing: target[%v].resource", i)
+	}
+
+	if target.Condition == "" && target.Expression == "" &&
+		target.JSONPath.Ready == "" {
+		return fmt.Errorf(
+			"missing: target[%v].condition, target[%v].expression, "+
+				"or target[%v].jsonPath.ready",
+			i, i, i,
+		)
+	}
+
+	if err := validateJSONPath(i, target.JSONPath); err != nil {
+		return err
+	}
+
+	if target.Condition != "" {
+		if _, _, ok := splitCondition(target.Condition); !ok {
 			return fmt.Errorf(
-				"target[%v].mode invalid: %v", i, target.Mode,
+				"target[%v].condition invalid: %v", i, target.Condition,
 			)
 		}
 	}
 
 	return nil
 }
+
+// validateTargetKindOptions validates the options of the target with the given
+// index that only apply to certain built-in kinds. Custom resources support
+// none of them, even if their kind has the same name as a built-in kind.
+func validateTargetKindOptions(i int, target target) error {
+	if target.APIVersion != "" {
+		if target.CheckConditions {
+			return fmt.Errorf(
+				"target[%v].checkConditions not supported for custom resources",
+				i,
+			)
+		}
+
+		if target.MaxAge != 0 {
+			return fmt.Errorf(
+				"target[%v].maxAge not supported for custom resources", i,
+			)
+		}
+
+		return nil
+	}
+
+	if target.CheckConditions && target.Kind != kindDeployment {
+		return fmt.Errorf(
+			"target[%v].checkConditions not supported for kind: %v",
+			i, target.Kind,
+		)
+	}
+
+	if target.Kind == kindCronJob {
+		if target.MaxAge <= 0 {
+			return fmt.Errorf("missing: target[%v].maxAge", i)
+		}
+	} else if target.MaxAge != 0 {
+		return fmt.Errorf(
+			"target[%v].maxAge not supported for kind: %v",
+			i, target.Kind,
+		)
+	}
+
+	return nil
+}
+
+// validateJSONPath validates the JSONPath templates of the target with the
+// given index.
+func validateJSONPath(i int, paths jsonPath) error {
+	if paths.Desired != "" && paths.Ready == "" {
+		return fmt.Errorf("missing: target[%v].jsonPath.ready", i)
+	}
+
+	for _, path := range []struct{ name, template string }{
+		{name: "ready", template: paths.Ready},
+		{name: "desired", template: paths.Desired},
+	} {
+		if path.template == "" {
+			continue
+		}
+
+		parser, err := kubejsonpath.Parse(path.name, path.template)
+		if err != nil {
+			return fmt.Errorf(
+				"target[%v].jsonPath.%v invalid: %v", i, path.name, err,
+			)
+		}
+
+		// Without braces, the template is only literal text and never
+		// extracts a value from the object.
+		if !slices.ContainsFunc(
+			parser.Root.Nodes,
+			func(node kubejsonpath.Node) bool {
+				return node.Type() != kubejsonpath.NodeText
+			},
+		) {
+			return fmt.Errorf(
+				"target[%v].jsonPath.%v invalid: no field reference in "+
+					"braces like {.status.readyReplicas}: %v",
+				i, path.name, path.template,
+			)
+		}
+	}
+
+	return nil
+}
+
+// splitCondition splits a condition in the format "Type=Status", for example
+// "Ready=True", into type and status. Both parts must not be empty.
+func splitCondition(condition string) (string, string, bool) {
+	conditionType, conditionStatus, found := strings.Cut(condition, "=")
+	if !found || conditionType == "" || conditionStatus == "" {
+		return "", "", false
+	}
+
+	return conditionType, conditionStatus, true
+}
+
+// validateTargetMode validates the mode of the target with the given index
+// together with the parameters that belong to the mode.
+func validateTargetMode(i int, target target) error {
+	allowedTargetModes := []string{
+		modeAllOfThem, modeAtLeastOne, modePercentage, modeMinReady,
+	}
+	if !slices.Contains(allowedTargetModes, target.Mode) {
+		return fmt.Errorf(
+			"target[%v].mode invalid: %v", i, target.Mode,
+		)
+	}
+
+	if target.Mode == modePercentage {
+		if target.Threshold < minThreshold || target.Threshold > maxThreshold {
+			return fmt.Errorf(
+				"target[%v].threshold invalid: %v", i, target.Threshold,
+			)
+		}
+	} else if target.Threshold != 0 {
+		return fmt.Errorf(
+			"target[%v].threshold not supported for mode: %v",
+			i, target.Mode,
+		)
+	}
+
+	// In all other modes, minReady is an optional floor on top of the mode.
+	if target.MinReady < 0 ||
+		target.Mode == modeMinReady && target.MinReady < 1 {
+		return fmt.Errorf(
+			"target[%v].minReady invalid: %v", i, target.MinReady,
+		)
+	}
+
+	return nil
+}
+
+// validateTargetSelection validates how the target with the given index
+// selects objects. Namespace and name are either given explicitly or selected
+// with label selectors. Nodes are cluster-scoped and have no namespace. Custom
+// resources without namespace are looked up cluster-wide.
+func validateTargetSelection(i int, target target) error {
+	switch {
+	case target.Kind == kindNode && target.APIVersion == "":
+		if target.Namespace != "" {
+			return fmt.Errorf(
+				"target[%v].namespace not supported for kind: %v",
+				i, target.Kind,
+			)
+		}
+
+		if target.NamespaceSelector != "" {
+			return fmt.Errorf(
+				"target[%v].namespaceSelector not supported for kind: %v",
+				i, target.Kind,
+			)
+		}
+	case target.Namespace == "" && target.NamespaceSelector == "" &&
+		target.APIVersion == "":
+		return fmt.Errorf(
+			"missing: target[%v].namespace or target[%v].namespaceSelector",
+			i, i,
+		)
+	case target.Namespace != "" && target.NamespaceSelector != "":
+		return fmt.Errorf(
+			"target[%v].namespace and target[%v].namespaceSelector "+
+				"are mutually exclusive",
+			i, i,
+		)
+	}
+
+	if (target.Kind == kindPod || target.Kind == kindNode) &&
+		target.APIVersion == "" {
+		if target.Name != "" {
+			return fmt.Errorf(
+				"target[%v].name not supported for kind: %v",
+				i, target.Kind,
+			)
+		}
+
+		if target.LabelSelector == "" {
+			return fmt.Errorf("missing: target[%v].labelSelector", i)
+		}
+	}
+
+	switch {
+	case target.Name == "" && target.LabelSelector == "":
+		return fmt.Errorf(
+			"missing: target[%v].name or target[%v].labelSelector", i, i,
+		)
+	case target.Name != "" && target.LabelSelector != "":
+		return fmt.Errorf(
+			"target[%v].name and target[%v].labelSelector "+
+				"are mutually exclusive",
+			i, i,
+		)
+	}
+
+	if _, err := kubelabels.Parse(target.LabelSelector); err != nil {
+		return fmt.Errorf("target[%v].labelSelector invalid: %v", i, err)
+	}
+
+	if _, err := kubelabels.Parse(target.NamespaceSelector); err != nil {
+		return fmt.Errorf(
+			"target[%v].namespaceSelector invalid: %v", i, err,
+		)
+	}
+
+	return nil
+}
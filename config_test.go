@@ -3,10 +3,13 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	cmp "github.com/google/go-cmp/cmp"
+	cmpopts "github.com/google/go-cmp/cmp/cmpopts"
 	dedent "github.com/lithammer/dedent"
 )
 
@@ -105,6 +108,31 @@ func TestNewConfig(t *testing.T) {
 		fileContent := dedent.Dedent(`
 			dryRun: true
 			seconds: 63
+			workers: 2
+			shutdown:
+			  publish: true
+			  value: -1
+			retry:
+			  attempts: 5
+			  initialBackoff: 2s
+			  maxBackoff: 30s
+			  exitAfterConsecutiveFailures: 4
+			buffer:
+			  enabled: true
+			  size: 100
+			  path: /tmp/buffer.json
+			server:
+			  enabled: true
+			  address: :9090
+			  livenessRounds: 5
+			outputs:
+			  - type: CloudWatch
+			  - type: EMF
+			    path: /tmp/metrics.log
+			    failurePolicy: BestEffort
+			  - type: OTLP
+			    endpoint: http://localhost:4318
+			    protocol: http/protobuf
 			logging:
 			  level: debug
 			  format: logfmt
@@ -135,6 +163,46 @@ func TestNewConfig(t *testing.T) {
 		wantConfig := config{
 			DryRun:  true,
 			Seconds: 63,
+			Workers: 2,
+			Shutdown: shutdown{
+				Publish: true,
+				Value:   -1,
+			},
+			Retry: retry{
+				Attempts:                     5,
+				InitialBackoff:               2 * time.Second,
+				MaxBackoff:                   30 * time.Second,
+				ExitAfterConsecutiveFailures: 4,
+			},
+			Buffer: buffer{
+				Enabled: true,
+				Size:    100,
+				Path:    "/tmp/buffer.json",
+			},
+			Server: server{
+				Enabled:        true,
+				Address:        ":9090",
+				LivenessRounds: 5,
+			},
+			Outputs: []output{{
+				Type:          outputTypeCloudWatch,
+				Path:          "",
+				Endpoint:      "",
+				Protocol:      "",
+				FailurePolicy: failurePolicyFatal,
+			}, {
+				Type:          outputTypeEMF,
+				Path:          "/tmp/metrics.log",
+				Endpoint:      "",
+				Protocol:      "",
+				FailurePolicy: failurePolicyBestEffort,
+			}, {
+				Type:          outputTypeOTLP,
+				Path:          "",
+				Endpoint:      "http://localhost:4318",
+				Protocol:      otlpProtocolHTTP,
+				FailurePolicy: failurePolicyFatal,
+			}},
 			Logging: logging{
 				Level:  "debug",
 				Format: "logfmt",
@@ -145,6 +213,7 @@ func TestNewConfig(t *testing.T) {
 				Dimensions: []dimension{
 					{Name: "Cluster", Value: "MyCluster"},
 				},
+				StorageResolution: storageResolutionStandard,
 			},
 			Targets: []target{
 				{
@@ -156,7 +225,16 @@ func TestNewConfig(t *testing.T) {
 			},
 		}
 
-		if diff := cmp.Diff(wantConfig, gotConfig); diff != "" {
+		wantConfig.Checks = []check{{
+			Name:    defaultCheckName,
+			Metric:  wantConfig.Metric,
+			Targets: wantConfig.Targets,
+		}}
+
+		diff := cmp.Diff(
+			wantConfig, gotConfig, cmpopts.IgnoreUnexported(target{}),
+		)
+		if diff != "" {
 			t.Errorf("Config mismatch (-want +got):\n%v", diff)
 		}
 	})
@@ -183,6 +261,51 @@ func TestProcessConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("WorkersTooSmall", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Workers = minWorkers - 1
+
+		processedConfig, err := processConfig(config)
+		if err != nil {
+			t.Fatalf("Failed to process config: %v", err)
+		}
+
+		if processedConfig.Workers != defaultWorkers {
+			t.Errorf(
+				"Unexpected workers value: got %v, want %v",
+				processedConfig.Workers,
+				defaultWorkers,
+			)
+		}
+	})
+
+	t.Run("ServerDefaults", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Server = server{Enabled: true, Address: "", LivenessRounds: 0}
+
+		processedConfig, err := processConfig(config)
+		if err != nil {
+			t.Fatalf("Failed to process config: %v", err)
+		}
+
+		rounds := processedConfig.Server.LivenessRounds
+		if rounds != defaultServerLivenessRounds {
+			t.Errorf(
+				"Unexpected liveness rounds: got %v, want %v",
+				rounds,
+				defaultServerLivenessRounds,
+			)
+		}
+
+		if processedConfig.Server.Address != defaultServerAddress {
+			t.Errorf(
+				"Unexpected server address: got %v, want %v",
+				processedConfig.Server.Address,
+				defaultServerAddress,
+			)
+		}
+	})
+
 	t.Run("InvalidMetric", func(t *testing.T) {
 		config := newExampleConfig(t)
 		config.Metric.Namespace = ""
@@ -213,6 +336,168 @@ func TestProcessConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("StorageResolution", func(t *testing.T) {
+		for _, tc := range []struct {
+			name              string // Name of test case.
+			seconds           int    // Scan interval.
+			storageResolution int32  // Configured storage resolution.
+			expResolution     int32  // Expected storage resolution.
+			errSubstr         string // Substring expected to be in error.
+		}{{
+			name:              "Default",
+			seconds:           60,
+			storageResolution: 0,
+			expResolution:     storageResolutionStandard,
+		}, {
+			name:              "HighWithSubMinuteSeconds",
+			seconds:           10,
+			storageResolution: storageResolutionHigh,
+			expResolution:     storageResolutionHigh,
+		}, {
+			name:              "HighWithMinuteSeconds",
+			seconds:           60,
+			storageResolution: storageResolutionHigh,
+			errSubstr: "metric.storageResolution 1 requires " +
+				"seconds below 60",
+		}, {
+			name:              "Invalid",
+			seconds:           10,
+			storageResolution: 5,
+			errSubstr:         "metric.storageResolution invalid: 5",
+		}} {
+			t.Run(tc.name, func(t *testing.T) {
+				config := newExampleConfig(t)
+				config.Seconds = tc.seconds
+				config.Metric.StorageResolution = tc.storageResolution
+
+				processedConfig, err := processConfig(config)
+
+				if tc.errSubstr != "" {
+					if err == nil {
+						t.Fatalf("Expected error, got nil")
+					}
+
+					if !strings.Contains(err.Error(), tc.errSubstr) {
+						t.Errorf(
+							"Expected error to contain %q, got %q",
+							tc.errSubstr,
+							err,
+						)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("Failed to process config: %v", err)
+				}
+
+				resolution := processedConfig.Metric.StorageResolution
+				if resolution != tc.expResolution {
+					t.Errorf(
+						"Unexpected storage resolution: got %v, want %v",
+						resolution,
+						tc.expResolution,
+					)
+				}
+			})
+		}
+	})
+
+	t.Run("BufferPathWithoutEnabled", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Buffer = buffer{Enabled: false, Size: 0, Path: "buffer.json"}
+
+		_, err := processConfig(config)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "buffer.path requires buffer.enabled"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+
+	t.Run("ServerAddressWithoutEnabled", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Server = server{
+			Enabled:        false,
+			Address:        ":9090",
+			LivenessRounds: 0,
+		}
+
+		_, err := processConfig(config)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "server.address requires server.enabled"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+
+	t.Run("InvalidOutputs", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Outputs = []output{{Type: "Foo"}}
+
+		_, err := processConfig(config)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "process outputs config: outputs[0].type invalid: Foo"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+
+	t.Run("PublishOnChangeWithoutInformers", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Informers = false
+		config.PublishOnChange = true
+
+		_, err := processConfig(config)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "publishOnChange requires informers"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+
+	t.Run("InvalidExpression", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Targets[0].Expression = "object.status.readyReplicas >="
+
+		_, err := processConfig(config)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "compile targets config: target[0].expression invalid"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+
+	t.Run("CompiledExpression", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Targets[0].Expression = "object.status.readyReplicas >= 2"
+
+		processedConfig, err := processConfig(config)
+		if err != nil {
+			t.Fatalf("Failed to process config: %v", err)
+		}
+
+		if processedConfig.Targets[0].program == nil {
+			t.Errorf("Expected compiled program, got nil")
+		}
+	})
+
 	t.Run("DefaultLogLevel", func(t *testing.T) {
 		config := newExampleConfig(t)
 		config.Logging.Level = ""
@@ -280,6 +565,392 @@ func TestProcessConfig(t *testing.T) {
 	})
 }
 
+// TestProcessChecks tests that the processChecks function builds a single
+// check from top-level metric and targets and validates configured checks.
+func TestProcessChecks(t *testing.T) {
+	newCheck := func(name, namespace string) check {
+		return check{
+			Name: name,
+			Metric: metric{
+				Namespace: namespace,
+				Name:      "Healthy",
+			},
+			Targets: []target{{
+				Kind:      kindDeployment,
+				Namespace: "Foo",
+				Name:      "Bar",
+				Mode:      modeAllOfThem,
+			}},
+		}
+	}
+
+	t.Run("BuiltFromMetricAndTargets", func(t *testing.T) {
+		config := newExampleConfig(t)
+
+		checks, err := processChecks(config)
+		if err != nil {
+			t.Fatalf("Failed to process checks: %v", err)
+		}
+
+		if len(checks) != 1 || checks[0].Name != defaultCheckName {
+			t.Fatalf("Expected single default check, got %v", checks)
+		}
+
+		if checks[0].Metric.Name != config.Metric.Name {
+			t.Errorf(
+				"Unexpected metric name: got %v, want %v",
+				checks[0].Metric.Name,
+				config.Metric.Name,
+			)
+		}
+	})
+
+	t.Run("Checks", func(t *testing.T) {
+		config := newExampleConfig(t)
+		config.Metric = metric{}
+		config.Targets = nil
+		config.Checks = []check{
+			newCheck("observability", "Observability"),
+			newCheck("ingress", "Ingress"),
+		}
+
+		checks, err := processChecks(config)
+		if err != nil {
+			t.Fatalf("Failed to process checks: %v", err)
+		}
+
+		names := []string{}
+		for _, check := range checks {
+			names = append(names, check.Name)
+
+			if check.Metric.StorageResolution != storageResolutionStandard {
+				t.Errorf(
+					"Unexpected storage resolution: %v",
+					check.Metric.StorageResolution,
+				)
+			}
+		}
+
+		if diff := cmp.Diff(
+			[]string{"observability", "ingress"}, names,
+		); diff != "" {
+			t.Errorf("Names mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	for _, tc := range []struct {
+		name      string  // Name of test case.
+		metric    metric  // Top-level metric.
+		checks    []check // Checks to process.
+		errSubstr string  // Substring expected to be in error string.
+	}{{
+		name:      "MixedWithMetric",
+		metric:    metric{Namespace: "Foo", Name: "Bar"},
+		checks:    []check{newCheck("a", "A")},
+		errSubstr: "checks not allowed together with metric or targets",
+	}, {
+		name:      "MissingName",
+		checks:    []check{newCheck("a", "A"), newCheck("", "B")},
+		errSubstr: "missing: checks[1].name",
+	}, {
+		name:      "DuplicateName",
+		checks:    []check{newCheck("a", "A"), newCheck("a", "B")},
+		errSubstr: "checks[1].name duplicate: a",
+	}, {
+		name:      "DuplicateMetric",
+		checks:    []check{newCheck("a", "A"), newCheck("b", "A")},
+		errSubstr: "checks[1].metric duplicate of checks[0].metric",
+	}, {
+		name: "InvalidTargets",
+		checks: []check{newCheck("a", "A"), {
+			Name:    "b",
+			Metric:  metric{Namespace: "B", Name: "Healthy"},
+			Targets: []target{{Kind: ""}},
+		}},
+		errSubstr: "checks[1]: validate targets config",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newExampleConfig(t)
+			config.Metric = tc.metric
+			config.Targets = nil
+			config.Checks = tc.checks
+
+			_, err := processChecks(config)
+			if err == nil {
+				t.Fatalf("Expected error, got nil")
+			}
+
+			if !strings.Contains(err.Error(), tc.errSubstr) {
+				t.Errorf(
+					"Expected error to contain %q, got %q",
+					tc.errSubstr,
+					err,
+				)
+			}
+		})
+	}
+}
+
+// TestProcessOutputs tests that the processOutputs function sets defaults
+// and validates the outputs.
+func TestProcessOutputs(t *testing.T) {
+	for _, tc := range []struct {
+		name        string   // Name of test case.
+		outputs     []output // Outputs to process.
+		wantOutputs []output // Expected outputs after processing.
+		errSubstr   string   // Substring expected to be in error string.
+	}{{
+		name:    "Default",
+		outputs: nil,
+		wantOutputs: []output{{
+			Type:          outputTypeCloudWatch,
+			FailurePolicy: failurePolicyFatal,
+		}},
+	}, {
+		name: "Multiple",
+		outputs: []output{{
+			Type: outputTypeCloudWatch,
+		}, {
+			Type:          outputTypeEMF,
+			Path:          "metrics.log",
+			FailurePolicy: failurePolicyBestEffort,
+		}},
+		wantOutputs: []output{{
+			Type:          outputTypeCloudWatch,
+			FailurePolicy: failurePolicyFatal,
+		}, {
+			Type:          outputTypeEMF,
+			Path:          "metrics.log",
+			FailurePolicy: failurePolicyBestEffort,
+		}},
+	}, {
+		name:      "MissingType",
+		outputs:   []output{{Type: outputTypeCloudWatch}, {Type: ""}},
+		errSubstr: "missing: outputs[1].type",
+	}, {
+		name:      "InvalidType",
+		outputs:   []output{{Type: "Foo"}},
+		errSubstr: "outputs[0].type invalid: Foo",
+	}, {
+		name:      "DuplicateType",
+		outputs:   []output{{Type: outputTypeEMF}, {Type: outputTypeEMF}},
+		errSubstr: "outputs[1].type duplicate: EMF",
+	}, {
+		name:      "PathWithoutEMF",
+		outputs:   []output{{Type: outputTypeCloudWatch, Path: "metrics.log"}},
+		errSubstr: "outputs[0].path requires type EMF",
+	}, {
+		name:      "InvalidFailurePolicy",
+		outputs:   []output{{Type: outputTypeEMF, FailurePolicy: "Foo"}},
+		errSubstr: "outputs[0].failurePolicy invalid: Foo",
+	}, {
+		name:    "OTLPDefaults",
+		outputs: []output{{Type: outputTypeOTLP}},
+		wantOutputs: []output{{
+			Type:          outputTypeOTLP,
+			Protocol:      otlpProtocolGRPC,
+			FailurePolicy: failurePolicyFatal,
+		}},
+	}, {
+		name: "OTLPExplicit",
+		outputs: []output{{
+			Type:     outputTypeOTLP,
+			Endpoint: "https://collector:4318",
+			Protocol: otlpProtocolHTTP,
+		}},
+		wantOutputs: []output{{
+			Type:          outputTypeOTLP,
+			Endpoint:      "https://collector:4318",
+			Protocol:      otlpProtocolHTTP,
+			FailurePolicy: failurePolicyFatal,
+		}},
+	}, {
+		name:      "EndpointWithoutOTLP",
+		outputs:   []output{{Type: outputTypeEMF, Endpoint: "http://a:4317"}},
+		errSubstr: "outputs[0].endpoint requires type OTLP",
+	}, {
+		name:      "ProtocolWithoutOTLP",
+		outputs:   []output{{Type: outputTypeEMF, Protocol: otlpProtocolGRPC}},
+		errSubstr: "outputs[0].protocol requires type OTLP",
+	}, {
+		name:      "InvalidProtocol",
+		outputs:   []output{{Type: outputTypeOTLP, Protocol: "http/json"}},
+		errSubstr: "outputs[0].protocol invalid: http/json",
+	}, {
+		name:      "InvalidEndpoint",
+		outputs:   []output{{Type: outputTypeOTLP, Endpoint: "collector:4317"}},
+		errSubstr: "outputs[0].endpoint invalid: collector:4317",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotOutputs, err := processOutputs(tc.outputs)
+
+			if tc.errSubstr != "" {
+				if err == nil {
+					t.Fatalf("Expected error, got nil")
+				}
+
+				if !strings.Contains(err.Error(), tc.errSubstr) {
+					t.Errorf(
+						"Expected error to contain %q, got %q",
+						tc.errSubstr,
+						err,
+					)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to process outputs: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.wantOutputs, gotOutputs); diff != "" {
+				t.Errorf("Outputs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestProcessRetry tests that the processRetry function sets defaults and
+// rejects a maximum backoff less than the initial backoff.
+func TestProcessRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name      string // Name of test case.
+		retry     retry  // Retry config to process.
+		expRetry  retry  // Expected processed retry config.
+		errSubstr string // Substring expected to be in error string.
+	}{{
+		name:  "Defaults",
+		retry: retry{},
+		expRetry: retry{
+			Attempts:                     defaultRetryAttempts,
+			InitialBackoff:               defaultRetryInitialBackoff,
+			MaxBackoff:                   defaultRetryMaxBackoff,
+			ExitAfterConsecutiveFailures: defaultExitAfterConsecutiveFailures,
+		},
+	}, {
+		name:  "MaxBackoffFollowsInitialBackoff",
+		retry: retry{InitialBackoff: time.Minute},
+		expRetry: retry{
+			Attempts:                     defaultRetryAttempts,
+			InitialBackoff:               time.Minute,
+			MaxBackoff:                   time.Minute,
+			ExitAfterConsecutiveFailures: defaultExitAfterConsecutiveFailures,
+		},
+	}, {
+		name: "Explicit",
+		retry: retry{
+			Attempts:                     1,
+			InitialBackoff:               time.Second,
+			MaxBackoff:                   time.Second,
+			ExitAfterConsecutiveFailures: 1,
+		},
+		expRetry: retry{
+			Attempts:                     1,
+			InitialBackoff:               time.Second,
+			MaxBackoff:                   time.Second,
+			ExitAfterConsecutiveFailures: 1,
+		},
+	}, {
+		name: "MaxBackoffTooSmall",
+		retry: retry{
+			InitialBackoff: time.Minute,
+			MaxBackoff:     time.Second,
+		},
+		errSubstr: "retry.maxBackoff 1s less than retry.initialBackoff 1m0s",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, err := processRetry(tc.retry)
+
+			if tc.errSubstr != "" {
+				if err == nil {
+					t.Fatalf("Expected error, got nil")
+				}
+
+				if !strings.Contains(err.Error(), tc.errSubstr) {
+					t.Errorf(
+						"Expected error to contain %q, got %q",
+						tc.errSubstr,
+						err,
+					)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.expRetry, retry); diff != "" {
+				t.Errorf("Retry mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestCompileExpression tests that the compileExpression function accepts
+// valid expressions and rejects expressions that fail parsing or
+// type-checking.
+func TestCompileExpression(t *testing.T) {
+	for _, tc := range []struct {
+		name       string // Name of test case.
+		expression string // Expression to compile.
+		errSubstr  string // Substring expected to be in error string.
+	}{{
+		name: "Valid",
+		expression: "object.status.readyReplicas >= 2 && " +
+			"object.status.updatedReplicas == object.spec.replicas",
+	}, {
+		name:       "ValidHas",
+		expression: "has(object.status.phase) && object.status.phase == 'Ok'",
+	}, {
+		name:       "SyntaxError",
+		expression: "object.status.readyReplicas >=",
+		errSubstr:  "Syntax error",
+	}, {
+		name:       "UndeclaredReference",
+		expression: "deployment.status.readyReplicas >= 2",
+		errSubstr:  "undeclared reference to 'deployment'",
+	}, {
+		name:       "NotBool",
+		expression: "object.status.readyReplicas",
+		errSubstr:  "must evaluate to bool, got dyn",
+	}, {
+		name:       "NotBoolString",
+		expression: "'ready'",
+		errSubstr:  "must evaluate to bool, got string",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			program, err := compileExpression(tc.expression)
+
+			if tc.errSubstr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				if program == nil {
+					t.Errorf("Expected program, got nil")
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Expected error, got nil")
+			}
+
+			if !strings.Contains(err.Error(), tc.errSubstr) {
+				t.Errorf(
+					"Expected error to contain %q, got %q",
+					tc.errSubstr,
+					err,
+				)
+			}
+		})
+	}
+}
+
 // TestValidateMetric tests the validateMetric function.
 func TestValidateMetric(t *testing.T) {
 	for _, tc := range []struct {
@@ -324,6 +995,41 @@ func TestValidateMetric(t *testing.T) {
 				{Name: "Name2", Value: "Value2"},
 			},
 		},
+	}, {
+		name: "PerTargetReservedDimension",
+		metric: metric{
+			Name:       "Name",
+			Namespace:  "Namespace",
+			Dimensions: []dimension{{Name: "TargetName", Value: "Value"}},
+			PerTarget:  true,
+		},
+		errSubstr: "metric.dimensions[0].name reserved: TargetName",
+	}, {
+		name: "PerTargetTooManyDimensions",
+		metric: metric{
+			Name:       "Name",
+			Namespace:  "Namespace",
+			Dimensions: slices.Repeat([]dimension{{"Name", "Value"}}, 28),
+			PerTarget:  true,
+		},
+		errSubstr: "metric.dimensions too many for per-target metrics: 28",
+	}, {
+		name: "ReplicaCountsReservedDimension",
+		metric: metric{
+			Name:          "Name",
+			Namespace:     "Namespace",
+			Dimensions:    []dimension{{Name: "TargetKind", Value: "Value"}},
+			ReplicaCounts: true,
+		},
+		errSubstr: "metric.dimensions[0].name reserved: TargetKind",
+	}, {
+		name: "PerTargetReservedDimensionIgnored",
+		metric: metric{
+			Name:       "Name",
+			Namespace:  "Namespace",
+			Dimensions: []dimension{{Name: "TargetName", Value: "Value"}},
+			PerTarget:  false,
+		},
 	}, {
 		name: "DimensionsNil",
 		metric: metric{
@@ -369,12 +1075,12 @@ func TestValidateTargets(t *testing.T) {
 			Name:      "Name",
 			Mode:      modeAllOfThem,
 		}, {
-			Kind:      "Job",
+			Kind:      "ReplicaSet",
 			Namespace: "Namespace",
 			Name:      "Name",
 			Mode:      modeAllOfThem,
 		}},
-		errSubstr: "target[1].kind invalid: Job",
+		errSubstr: "target[1].kind invalid: ReplicaSet",
 	}, {
 		name: "ModeNotSupported",
 		targets: []target{{
@@ -384,6 +1090,377 @@ func TestValidateTargets(t *testing.T) {
 			Mode:      "AtLeastTwo",
 		}},
 		errSubstr: "target[0].mode invalid: AtLeastTwo",
+	}, {
+		name: "PercentageThresholdMissing",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modePercentage,
+		}},
+		errSubstr: "target[0].threshold invalid: 0",
+	}, {
+		name: "PercentageThresholdTooLarge",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modePercentage,
+			Threshold: 101,
+		}},
+		errSubstr: "target[0].threshold invalid: 101",
+	}, {
+		name: "ThresholdNotSupported",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+			Threshold: 80,
+		}},
+		errSubstr: "target[0].threshold not supported for mode: AllOfThem",
+	}, {
+		name: "MinReadyMissing",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeMinReady,
+		}},
+		errSubstr: "target[0].minReady invalid: 0",
+	}, {
+		name: "MinReadyNegative",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAtLeastOne,
+			MinReady:  -1,
+		}},
+		errSubstr: "target[0].minReady invalid: -1",
+	}, {
+		name: "MinReadyFloor",
+		targets: []target{{
+			Kind:          kindNode,
+			LabelSelector: "pool=a",
+			Mode:          modeAllOfThem,
+			MinReady:      3,
+		}, {
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modePercentage,
+			Threshold: 80,
+			MinReady:  2,
+		}},
+	}, {
+		name: "ModesAllIsGood",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modePercentage,
+			Threshold: 80,
+		}, {
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeMinReady,
+			MinReady:  3,
+		}},
+	}, {
+		name: "CheckConditionsNotSupported",
+		targets: []target{{
+			Kind:            kindStatefulSet,
+			Namespace:       "Namespace",
+			Name:            "Name",
+			Mode:            modeAllOfThem,
+			CheckConditions: true,
+		}},
+		errSubstr: "target[0].checkConditions not supported for kind: " +
+			"StatefulSet",
+	}, {
+		name: "CronJobMaxAgeMissing",
+		targets: []target{{
+			Kind:      kindCronJob,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+		}},
+		errSubstr: "missing: target[0].maxAge",
+	}, {
+		name: "MaxAgeNotSupported",
+		targets: []target{{
+			Kind:      kindJob,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+			MaxAge:    time.Hour,
+		}},
+		errSubstr: "target[0].maxAge not supported for kind: Job",
+	}, {
+		name: "CustomCronJobWithoutMaxAge",
+		targets: []target{{
+			APIVersion: "example.com/v1",
+			Kind:       kindCronJob,
+			Resource:   "cronjobs",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready=True",
+		}},
+	}, {
+		name: "CustomCronJobMaxAgeNotSupported",
+		targets: []target{{
+			APIVersion: "example.com/v1",
+			Kind:       kindCronJob,
+			Resource:   "cronjobs",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready=True",
+			MaxAge:     time.Hour,
+		}},
+		errSubstr: "target[0].maxAge not supported for custom resources",
+	}, {
+		name: "CustomDeploymentCheckConditionsNotSupported",
+		targets: []target{{
+			APIVersion:      "example.com/v1",
+			Kind:            kindDeployment,
+			Resource:        "deployments",
+			Namespace:       "Namespace",
+			Name:            "Name",
+			Mode:            modeAllOfThem,
+			Condition:       "Ready=True",
+			CheckConditions: true,
+		}},
+		errSubstr: "target[0].checkConditions not supported for custom " +
+			"resources",
+	}, {
+		name: "NodeNamespaceNotSupported",
+		targets: []target{{
+			Kind:          kindNode,
+			Namespace:     "Namespace",
+			LabelSelector: "pool=a",
+			Mode:          modeAllOfThem,
+		}},
+		errSubstr: "target[0].namespace not supported for kind: Node",
+	}, {
+		name: "NodeNamespaceSelectorNotSupported",
+		targets: []target{{
+			Kind:              kindNode,
+			NamespaceSelector: "team=a",
+			LabelSelector:     "pool=a",
+			Mode:              modeAllOfThem,
+		}},
+		errSubstr: "target[0].namespaceSelector not supported for kind: Node",
+	}, {
+		name: "NodeNameNotSupported",
+		targets: []target{{
+			Kind: kindNode,
+			Name: "Name",
+			Mode: modeAllOfThem,
+		}},
+		errSubstr: "target[0].name not supported for kind: Node",
+	}, {
+		name: "NodeLabelSelectorMissing",
+		targets: []target{{
+			Kind: kindNode,
+			Mode: modeAllOfThem,
+		}},
+		errSubstr: "missing: target[0].labelSelector",
+	}, {
+		name: "NodeAllIsGood",
+		targets: []target{{
+			Kind:          kindNode,
+			LabelSelector: "eks.amazonaws.com/nodegroup=main",
+			Mode:          modeMinReady,
+			MinReady:      2,
+		}},
+	}, {
+		name: "ResourceWithoutAPIVersion",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Resource:  "deployments",
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+		}},
+		errSubstr: "target[0].resource not supported for kind: Deployment",
+	}, {
+		name: "ConditionWithoutAPIVersion",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+			Condition: "Ready=True",
+		}},
+		errSubstr: "target[0].condition not supported for kind: Deployment",
+	}, {
+		name: "CustomAPIVersionInvalid",
+		targets: []target{{
+			APIVersion: "a/b/c",
+			Kind:       "Certificate",
+			Resource:   "certificates",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready=True",
+		}},
+		errSubstr: "target[0].apiVersion invalid",
+	}, {
+		name: "CustomResourceMissing",
+		targets: []target{{
+			APIVersion: "cert-manager.io/v1",
+			Kind:       "Certificate",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready=True",
+		}},
+		errSubstr: "missing: target[0].resource",
+	}, {
+		name: "CustomConditionMissing",
+		targets: []target{{
+			APIVersion: "cert-manager.io/v1",
+			Kind:       "Certificate",
+			Resource:   "certificates",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+		}},
+		errSubstr: "missing: target[0].condition, target[0].expression, " +
+			"or target[0].jsonPath.ready",
+	}, {
+		name: "CustomConditionInvalid",
+		targets: []target{{
+			APIVersion: "cert-manager.io/v1",
+			Kind:       "Certificate",
+			Resource:   "certificates",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready",
+		}},
+		errSubstr: "target[0].condition invalid: Ready",
+	}, {
+		name: "CustomExpressionWithoutCondition",
+		targets: []target{{
+			APIVersion: "kustomize.toolkit.fluxcd.io/v1",
+			Kind:       "Kustomization",
+			Resource:   "kustomizations",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Expression: "object.status.lastAppliedRevision != ''",
+		}},
+	}, {
+		name: "JSONPathNotSupported",
+		targets: []target{{
+			Kind:      kindDeployment,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+			JSONPath:  jsonPath{Ready: "{.status.readyReplicas}"},
+		}},
+		errSubstr: "target[0].jsonPath not supported for kind: Deployment",
+	}, {
+		name: "JSONPathReadyMissing",
+		targets: []target{{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Rollout",
+			Resource:   "rollouts",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Healthy=True",
+			JSONPath:   jsonPath{Desired: "{.spec.replicas}"},
+		}},
+		errSubstr: "missing: target[0].jsonPath.ready",
+	}, {
+		name: "JSONPathDesiredInvalid",
+		targets: []target{{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Rollout",
+			Resource:   "rollouts",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			JSONPath: jsonPath{
+				Ready:   "{.status.readyReplicas}",
+				Desired: "{.spec.replicas",
+			},
+		}},
+		errSubstr: "target[0].jsonPath.desired invalid",
+	}, {
+		name: "JSONPathWithoutBraces",
+		targets: []target{{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Rollout",
+			Resource:   "rollouts",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			JSONPath:   jsonPath{Ready: ".status.readyReplicas"},
+		}},
+		errSubstr: "target[0].jsonPath.ready invalid: no field reference",
+	}, {
+		name: "JSONPathAllIsGood",
+		targets: []target{{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Rollout",
+			Resource:   "rollouts",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modePercentage,
+			Threshold:  50,
+			JSONPath: jsonPath{
+				Ready:   "{.status.readyReplicas}",
+				Desired: "{.spec.replicas}",
+			},
+		}, {
+			APIVersion:    "helm.toolkit.fluxcd.io/v2",
+			Kind:          "HelmRelease",
+			Resource:      "helmreleases",
+			LabelSelector: "app=foo",
+			Mode:          modeAllOfThem,
+			JSONPath: jsonPath{
+				Ready: "{.status.conditions[?(@.type=='Ready')].status}",
+			},
+		}},
+	}, {
+		name: "CustomAllIsGood",
+		targets: []target{{
+			APIVersion: "argoproj.io/v1alpha1",
+			Kind:       "Rollout",
+			Resource:   "rollouts",
+			Namespace:  "Namespace",
+			Name:       "Name",
+			Mode:       modeAllOfThem,
+			Condition:  "Healthy=True",
+		}, {
+			APIVersion:    "cert-manager.io/v1",
+			Kind:          "ClusterIssuer",
+			Resource:      "clusterissuers",
+			LabelSelector: "app=foo",
+			Mode:          modeAllOfThem,
+			Condition:     "Ready=True",
+		}},
+	}, {
+		name: "JobsAllIsGood",
+		targets: []target{{
+			Kind:      kindJob,
+			Namespace: "Namespace",
+			Name:      "Name",
+			Mode:      modeAllOfThem,
+		}, {
+			Kind:          kindCronJob,
+			Namespace:     "Namespace",
+			LabelSelector: "app=foo",
+			Mode:          modeAllOfThem,
+			MaxAge:        26 * time.Hour,
+		}},
 	}, {
 		name: "KindEmpty",
 		targets: []target{{
@@ -411,6 +1488,91 @@ func TestValidateTargets(t *testing.T) {
 			Mode:      modeAtLeastOne,
 		}},
 		errSubstr: "missing: target[0].namespace",
+	}, {
+		name: "PodLabelSelectorEmpty",
+		targets: []target{{
+			Kind:      kindPod,
+			Namespace: "Namespace",
+			Mode:      modeAllOfThem,
+		}},
+		errSubstr: "missing: target[0].labelSelector",
+	}, {
+		name: "PodLabelSelectorInvalid",
+		targets: []target{{
+			Kind:          kindPod,
+			Namespace:     "Namespace",
+			LabelSelector: "app in (",
+			Mode:          modeAllOfThem,
+		}},
+		errSubstr: "target[0].labelSelector invalid",
+	}, {
+		name: "PodNameNotSupported",
+		targets: []target{{
+			Kind:          kindPod,
+			Namespace:     "Namespace",
+			Name:          "Name",
+			LabelSelector: "app=foo",
+			Mode:          modeAllOfThem,
+		}},
+		errSubstr: "target[0].name not supported for kind: Pod",
+	}, {
+		name: "NameAndLabelSelector",
+		targets: []target{{
+			Kind:          kindDeployment,
+			Namespace:     "Namespace",
+			Name:          "Name",
+			LabelSelector: "app=foo",
+			Mode:          modeAllOfThem,
+		}},
+		errSubstr: "target[0].name and target[0].labelSelector " +
+			"are mutually exclusive",
+	}, {
+		name: "NamespaceAndNamespaceSelector",
+		targets: []target{{
+			Kind:              kindDeployment,
+			Namespace:         "Namespace",
+			NamespaceSelector: "team=foo",
+			Name:              "Name",
+			Mode:              modeAllOfThem,
+		}},
+		errSubstr: "target[0].namespace and target[0].namespaceSelector " +
+			"are mutually exclusive",
+	}, {
+		name: "NamespaceSelectorInvalid",
+		targets: []target{{
+			Kind:              kindDeployment,
+			NamespaceSelector: "team in (",
+			Name:              "Name",
+			Mode:              modeAllOfThem,
+		}},
+		errSubstr: "target[0].namespaceSelector invalid",
+	}, {
+		name: "OnNoMatchInvalid",
+		targets: []target{{
+			Kind:          kindDeployment,
+			Namespace:     "Namespace",
+			LabelSelector: "app=foo",
+			Mode:          modeAllOfThem,
+			OnNoMatch:     "Maybe",
+		}},
+		errSubstr: "target[0].onNoMatch invalid: Maybe",
+	}, {
+		name: "SelectorsAllIsGood",
+		targets: []target{{
+			Kind:              kindStatefulSet,
+			NamespaceSelector: "team=foo",
+			LabelSelector:     "app=foo",
+			Mode:              modeAllOfThem,
+			OnNoMatch:         noMatchReady,
+		}},
+	}, {
+		name: "PodAllIsGood",
+		targets: []target{{
+			Kind:          kindPod,
+			Namespace:     "Namespace",
+			LabelSelector: "app=foo,tier!=cache",
+			Mode:          modeAtLeastOne,
+		}},
 	}, {
 		name: "ModeEmpty",
 		targets: []target{{
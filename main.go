@@ -3,21 +3,58 @@ package main
 import (
 	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	cel "cel.dev/cel-go/cel"
 	aws "github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	cw "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	sts "github.com/aws/aws-sdk-go-v2/service/sts"
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	collectors "github.com/prometheus/client_golang/prometheus/collectors"
+	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
+	attribute "go.opentelemetry.io/otel/attribute"
+	otlpmetricgrpc "go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	otlpmetrichttp "go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	instrumentation "go.opentelemetry.io/otel/sdk/instrumentation"
+	metricdata "go.opentelemetry.io/otel/sdk/metric/metricdata"
+	resource "go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	kubeappsv1 "k8s.io/api/apps/v1"
+	kubebatchv1 "k8s.io/api/batch/v1"
+	kubecorev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	kubemetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kubelabels "k8s.io/apimachinery/pkg/labels"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	kubedynamic "k8s.io/client-go/dynamic"
+	kubedynamicinformer "k8s.io/client-go/dynamic/dynamicinformer"
+	kubeinformers "k8s.io/client-go/informers"
 	kube "k8s.io/client-go/kubernetes"
 	kuberest "k8s.io/client-go/rest"
+	kubecache "k8s.io/client-go/tools/cache"
 	kubeclientcmd "k8s.io/client-go/tools/clientcmd"
+	kubejsonpath "k8s.io/client-go/util/jsonpath"
 )
 
 var (
@@ -28,9 +65,17 @@ var (
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM,
+	)
+
+	// Restore default signal handling after the first signal, so that a
+	// second one terminates the program right away.
+	context.AfterFunc(ctx, stop)
 
 	exitCode := runMain(ctx, nil)
+
+	stop()
 	os.Exit(exitCode)
 }
 
@@ -114,7 +159,33 @@ func runMain(ctx context.Context, log *slog.Logger) int {
 		slog.String("gitCommit", gitCommit),
 	)
 
-	kubernetesClient, err := newKubernetesClient()
+	var (
+		prom   *exporter
+		health *probes
+	)
+
+	// The server is started first, so that the probes are available while
+	// clients are verified and the cache is synced.
+	if config.Server.Enabled {
+		prom = newExporter()
+		interval := time.Duration(config.Seconds) * time.Second
+		health = newProbes(
+			interval, time.Duration(config.Server.LivenessRounds)*interval,
+		)
+
+		stopServer, err := startServer(
+			ctx, log, newServer(config.Server.Address, prom, health),
+		)
+		if err != nil {
+			log.Error("Failed to start server.", slog.Any("error", err))
+
+			return 1
+		}
+
+		defer stopServer()
+	}
+
+	kubernetesClient, dynamicClient, err := newKubernetesClients()
 	if err != nil {
 		log.Error(
 			"Failed to create Kubernetes client.",
@@ -124,26 +195,69 @@ func runMain(ctx context.Context, log *slog.Logger) int {
 		return 1
 	}
 
-	cloudwatchClient, err := newCloudwatchClient(ctx)
+	err = verifyCustomResources(
+		kubernetesClient, config.Checks, config.Informers,
+	)
 	if err != nil {
 		log.Error(
-			"Failed to create CloudWatch client.",
+			"Failed to verify custom resource targets.",
 			slog.Any("error", err),
 		)
 
 		return 1
 	}
 
+	sinks, closeSinks, err := newSinks(ctx, log, config, prom)
+	if err != nil {
+		log.Error("Failed to create outputs.", slog.Any("error", err))
+
+		return 1
+	}
+
+	defer closeSinks()
+
+	health.markVerified()
+
+	var cache *informerCache
+
+	if config.Informers {
+		log.Info("Starting informers and waiting for cache sync.")
+
+		targets := []target{}
+		for _, check := range config.Checks {
+			targets = append(targets, check.Targets...)
+		}
+
+		cache, err = newInformerCache(
+			ctx, kubernetesClient, dynamicClient, targets,
+		)
+		if err != nil {
+			log.Error(
+				"Failed to create informer cache.",
+				slog.Any("error", err),
+			)
+
+			return 1
+		}
+	}
+
 	if err = executeRounds(&executeRoundsOptions{
-		ctx:      ctx,
-		log:      log,
-		dry:      config.DryRun,
-		kClient:  kubernetesClient,
-		cwClient: cloudwatchClient,
-		single:   false,
-		seconds:  config.Seconds,
-		metric:   config.Metric,
-		targets:  config.Targets,
+		ctx:               ctx,
+		log:               log,
+		kClient:           kubernetesClient,
+		dClient:           dynamicClient,
+		cache:             cache,
+		sinks:             sinks,
+		publishOnChange:   config.PublishOnChange,
+		shutdown:          config.Shutdown,
+		shutdownTimeout:   shutdownTimeout,
+		exitAfterFailures: config.Retry.ExitAfterConsecutiveFailures,
+		single:            false,
+		seconds:           config.Seconds,
+		workers:           config.Workers,
+		checks:            config.Checks,
+		exporter:          prom,
+		probes:            health,
 	}); err != nil {
 		log.Error(
 			"Failure during round execution.",
@@ -156,8 +270,14 @@ func runMain(ctx context.Context, log *slog.Logger) int {
 	return 0
 }
 
-// newKubernetesClient creates and configures a new Kubernetes client.
-func newKubernetesClient() (*kube.Clientset, error) {
+// newKubernetesClients creates and configures new Kubernetes clients. The
+// typed client is used for built-in kinds and the dynamic client for custom
+// resources.
+func newKubernetesClients() (
+	*kube.Clientset,
+	*kubedynamic.DynamicClient,
+	error,
+) {
 	var config *kuberest.Config
 
 	var err error
@@ -165,7 +285,7 @@ func newKubernetesClient() (*kube.Clientset, error) {
 	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
 		config, err = kuberest.InClusterConfig()
 		if err != nil {
-			return nil, fmt.Errorf(
+			return nil, nil, fmt.Errorf(
 				"create in-cluster Kubernetes config: %v", err,
 			)
 		}
@@ -176,7 +296,7 @@ func newKubernetesClient() (*kube.Clientset, error) {
 		).
 			ClientConfig()
 		if err != nil {
-			return nil, fmt.Errorf(
+			return nil, nil, fmt.Errorf(
 				"create out-of-cluster Kubernetes config: %v", err,
 			)
 		}
@@ -184,21 +304,348 @@ func newKubernetesClient() (*kube.Clientset, error) {
 
 	client, err := kube.NewForConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"create Kubernetes client: %v", err,
 		)
 	}
 
 	if _, err = client.Discovery().ServerVersion(); err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"get Kubernetes server version: %v", err,
 		)
 	}
 
-	return client, nil
+	dynamicClient, err := kubedynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"create dynamic Kubernetes client: %v", err,
+		)
+	}
+
+	return client, dynamicClient, nil
+}
+
+// verifyCustomResources checks the custom resource targets of the given
+// checks against the resources served by the Kubernetes API. Targets of
+// namespaced resources must select a namespace, which cannot be validated
+// without knowing the scope of the resource. Without informers, resources
+// that are not served are skipped, as their targets fail during scans anyway.
+// With informers, they are rejected, as their informers never sync.
+func verifyCustomResources(
+	client kube.Interface,
+	checks []check,
+	informers bool,
+) error {
+	for i, check := range checks {
+		for j, target := range check.Targets {
+			selected := target.Namespace != "" || target.NamespaceSelector != ""
+			if target.APIVersion == "" || selected && !informers {
+				continue
+			}
+
+			resource, served, err := findServedResource(client, target)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case !served && informers:
+				return fmt.Errorf(
+					"checks[%v]: target[%v].resource not served, which is "+
+						"required by informers: %v",
+					i, j, target.Resource,
+				)
+			case served && resource.Namespaced && !selected:
+				return fmt.Errorf(
+					"checks[%v]: missing: target[%v].namespace or "+
+						"target[%v].namespaceSelector for namespaced "+
+						"resource: %v",
+					i, j, j, target.Resource,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// findServedResource returns the resource of the given custom resource target
+// as served by the Kubernetes API and whether it is served at all.
+func findServedResource(
+	client kube.Interface,
+	target target,
+) (kubemetav1.APIResource, bool, error) {
+	var none kubemetav1.APIResource
+
+	resources, err := client.Discovery().
+		ServerResourcesForGroupVersion(target.APIVersion)
+	if kubeerrors.IsNotFound(err) {
+		return none, false, nil
+	}
+
+	if err != nil {
+		return none, false, fmt.Errorf(
+			"get resources of %v: %v", target.APIVersion, err,
+		)
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Name == target.Resource {
+			return resource, true, nil
+		}
+	}
+
+	return none, false, nil
+}
+
+// Maximum time to wait for the initial sync of the informer cache.
+const cacheSyncTimeout = time.Minute
+
+// informerScope restricts the informers of a factory to a namespace and a
+// label selector, so that only objects relevant for targets are listed,
+// watched, and cached. An empty namespace stands for all namespaces or a
+// cluster-scoped kind.
+type informerScope struct {
+	namespace string
+	selector  string
+}
+
+// objectScope returns the scope of the informers for the objects of the given
+// target. Targets with a namespace selector watch all namespaces, as
+// namespaces cannot be selected by label when listing objects.
+func objectScope(target target) informerScope {
+	return informerScope{
+		namespace: target.Namespace,
+		selector:  target.LabelSelector,
+	}
+}
+
+// namespaceScope returns the scope of the namespace informer of the given
+// target.
+func namespaceScope(target target) informerScope {
+	return informerScope{namespace: "", selector: target.NamespaceSelector}
+}
+
+// informerCache is a local watch cache backed by shared informers. It is kept
+// up to date by watches and allows scans without requests to the Kubernetes
+// API. There is one factory per scope used by the targets.
+type informerCache struct {
+	factories        map[informerScope]kubeinformers.SharedInformerFactory
+	dynamicFactories map[informerScope]kubedynamicinformer.
+				DynamicSharedInformerFactory
+
+	// Receives a value whenever a watched object is added, updated, or
+	// deleted. Buffered with a size of one, so bursts of changes coalesce.
+	changes chan struct{}
+}
+
+// factory returns the informer factory of the given scope. It must have been
+// created by newInformerCache.
+func (c *informerCache) factory(
+	scope informerScope,
+) kubeinformers.SharedInformerFactory {
+	return c.factories[scope]
+}
+
+// dynamicFactory returns the dynamic informer factory of the given scope. It
+// must have been created by newInformerCache.
+func (c *informerCache) dynamicFactory(
+	scope informerScope,
+) kubedynamicinformer.DynamicSharedInformerFactory {
+	return c.dynamicFactories[scope]
+}
+
+// notify signals a change without blocking.
+func (c *informerCache) notify() {
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
+
+// newInformerCache creates an informer cache for the given targets, starts
+// the informers, and waits until the caches are synced. Informers are scoped
+// to the namespace and label selector of the targets and stop once the given
+// context is done.
+func newInformerCache(
+	ctx context.Context,
+	kClient kube.Interface,
+	dClient kubedynamic.Interface,
+	targets []target,
+) (*informerCache, error) {
+	cache := &informerCache{
+		factories: map[informerScope]kubeinformers.SharedInformerFactory{},
+		dynamicFactories: map[informerScope]kubedynamicinformer.
+			DynamicSharedInformerFactory{},
+		changes: make(chan struct{}, 1),
+	}
+
+	// Informers are registered when they are requested for the first time.
+	// Only registered informers are started.
+	informers := []kubecache.SharedIndexInformer{}
+
+	for _, target := range targets {
+		targetInformers, err := cache.register(kClient, dClient, target)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, informer := range targetInformers {
+			if !slices.Contains(informers, informer) {
+				informers = append(informers, informer)
+			}
+		}
+	}
+
+	for _, informer := range informers {
+		if _, err := informer.AddEventHandler(
+			kubecache.ResourceEventHandlerFuncs{
+				AddFunc:    func(any) { cache.notify() },
+				UpdateFunc: func(any, any) { cache.notify() },
+				DeleteFunc: func(any) { cache.notify() },
+			},
+		); err != nil {
+			return nil, fmt.Errorf("add event handler: %v", err)
+		}
+	}
+
+	if err := cache.start(ctx); err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// register returns the informers required by the given target. Factories of
+// scopes that are requested for the first time are created.
+func (c *informerCache) register(
+	kClient kube.Interface,
+	dClient kubedynamic.Interface,
+	target target,
+) ([]kubecache.SharedIndexInformer, error) {
+	informers := []kubecache.SharedIndexInformer{}
+
+	if target.NamespaceSelector != "" {
+		factory := c.addFactory(kClient, namespaceScope(target))
+		informers = append(
+			informers, factory.Core().V1().Namespaces().Informer(),
+		)
+	}
+
+	if target.APIVersion != "" {
+		groupVersion, err := kubeschema.ParseGroupVersion(target.APIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("parse apiVersion: %v", err)
+		}
+
+		factory := c.addDynamicFactory(dClient, objectScope(target))
+
+		return append(informers, factory.
+			ForResource(groupVersion.WithResource(target.Resource)).
+			Informer()), nil
+	}
+
+	factory := c.addFactory(kClient, objectScope(target))
+
+	var informer kubecache.SharedIndexInformer
+
+	switch target.Kind {
+	case kindDaemonSet:
+		informer = factory.Apps().V1().DaemonSets().Informer()
+	case kindDeployment:
+		informer = factory.Apps().V1().Deployments().Informer()
+	case kindStatefulSet:
+		informer = factory.Apps().V1().StatefulSets().Informer()
+	case kindPod:
+		informer = factory.Core().V1().Pods().Informer()
+	case kindJob:
+		informer = factory.Batch().V1().Jobs().Informer()
+	case kindCronJob:
+		informer = factory.Batch().V1().CronJobs().Informer()
+	case kindNode:
+		informer = factory.Core().V1().Nodes().Informer()
+	default:
+		return nil, fmt.Errorf("unsupported kind: %v", target.Kind)
+	}
+
+	return append(informers, informer), nil
+}
+
+// addFactory returns the informer factory of the given scope. It is created
+// if it does not exist yet. Not safe for concurrent use.
+func (c *informerCache) addFactory(
+	client kube.Interface,
+	scope informerScope,
+) kubeinformers.SharedInformerFactory {
+	if _, ok := c.factories[scope]; !ok {
+		c.factories[scope] = kubeinformers.NewSharedInformerFactoryWithOptions(
+			client, 0,
+			kubeinformers.WithNamespace(scope.namespace),
+			kubeinformers.WithTweakListOptions(
+				func(options *kubemetav1.ListOptions) {
+					options.LabelSelector = scope.selector
+				},
+			),
+		)
+	}
+
+	return c.factories[scope]
+}
+
+// addDynamicFactory returns the dynamic informer factory of the given scope.
+// It is created if it does not exist yet. Not safe for concurrent use.
+func (c *informerCache) addDynamicFactory(
+	client kubedynamic.Interface,
+	scope informerScope,
+) kubedynamicinformer.DynamicSharedInformerFactory {
+	if _, ok := c.dynamicFactories[scope]; !ok {
+		c.dynamicFactories[scope] = kubedynamicinformer.
+			NewFilteredDynamicSharedInformerFactory(
+				client, 0, scope.namespace,
+				func(options *kubemetav1.ListOptions) {
+					options.LabelSelector = scope.selector
+				},
+			)
+	}
+
+	return c.dynamicFactories[scope]
+}
+
+// start starts the informers of all factories and waits until their caches
+// are synced.
+func (c *informerCache) start(ctx context.Context) error {
+	for _, factory := range c.factories {
+		factory.Start(ctx.Done())
+	}
+
+	for _, factory := range c.dynamicFactories {
+		factory.Start(ctx.Done())
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	defer cancel()
+
+	for _, factory := range c.factories {
+		for informerType, ok := range factory.WaitForCacheSync(syncCtx.Done()) {
+			if !ok {
+				return fmt.Errorf("sync cache for %v", informerType)
+			}
+		}
+	}
+
+	for _, factory := range c.dynamicFactories {
+		for resource, ok := range factory.WaitForCacheSync(syncCtx.Done()) {
+			if !ok {
+				return fmt.Errorf("sync cache for %v", resource)
+			}
+		}
+	}
+
+	return nil
 }
 
-// newCloudwatchClient creates and configures a new CloudWatch client.
+// newCloudwatchClient creates and configures a new CloudWatch client. Retries
+// of the SDK are disabled in favor of the configured ones.
 func newCloudwatchClient(ctx context.Context) (*cw.Client, error) {
 	config, err := awsconfig.LoadDefaultConfig(ctx)
 	if err != nil {
@@ -213,18 +660,43 @@ func newCloudwatchClient(ctx context.Context) (*cw.Client, error) {
 		return nil, fmt.Errorf("get AWS caller identity: %v", err)
 	}
 
-	return cw.NewFromConfig(config), nil
+	return cw.NewFromConfig(config, func(o *cw.Options) {
+		o.Retryer = aws.NopRetryer{}
+	}), nil
 }
 
 // executeRoundsOptions holds the input for the executeRounds function.
 type executeRoundsOptions struct {
 	ctx context.Context
 	log *slog.Logger
-	dry bool
 
-	// Clients for Kubernetes and CloudWatch.
-	kClient  kube.Interface
-	cwClient cwPutMetricDataAPI
+	// Clients for Kubernetes. The dynamic client is used for custom
+	// resources.
+	kClient kube.Interface
+	dClient kubedynamic.Interface
+
+	// Sinks the scans of every round are published with, one after another.
+	sinks []outputSink
+
+	// Local watch cache. If set, targets are read from it instead of the
+	// Kubernetes API.
+	cache *informerCache
+
+	// Publish on change flag. If enabled, the metric is also published as
+	// soon as the aggregate status in the cache changes.
+	publishOnChange bool
+
+	// Behavior on shutdown. If publishing is enabled, the configured value is
+	// published once the context is canceled.
+	shutdown shutdown
+
+	// Time the round in flight and publishing the shutdown value may each take
+	// once the context is canceled. Zero lets them finish without a deadline.
+	shutdownTimeout time.Duration
+
+	// Number of consecutive failed rounds of a fatal sink after which
+	// executeRounds gives up.
+	exitAfterFailures int
 
 	// Single run flag. If enabled, only a single tick round is executed.
 	single bool
@@ -232,49 +704,115 @@ type executeRoundsOptions struct {
 	// Seconds between tick rounds.
 	seconds int
 
-	// Metric to update.
-	metric metric
+	// Number of targets scanned concurrently.
+	workers int
 
-	// Targets to scan.
-	targets []target
+	// Checks to scan and publish. Every check has its own metric and targets.
+	checks []check
+
+	// Prometheus metrics updated with every scan and tick. Nil disables them.
+	exporter *exporter
+
+	// Liveness and readiness probes updated with every published round. Nil
+	// disables them.
+	probes *probes
 }
 
-// executeRounds executes tick rounds.
+// executeRounds executes tick rounds. Every round scans the targets of all
+// checks and publishes the scans with all sinks. If publishing on change is
+// enabled, every change in the informer cache triggers an additional scan
+// and the scans are published right away if the aggregate status of any
+// check changed.
+//
+// Canceling the context stops the rounds. A round that is already in flight
+// is finished with a context that is only canceled once the shutdown timeout
+// has passed, so that retries cannot delay the shutdown without limit.
+//
+// Failures of fatal sinks only stop the rounds once the configured number of
+// consecutive failures is reached. Failures of best-effort sinks never stop
+// the rounds.
 func executeRounds(o *executeRoundsOptions) error {
 	tickCount := 0
 
 	ticker := time.NewTicker(time.Duration(o.seconds) * time.Second)
 	defer ticker.Stop()
 
+	// Receiving from a nil channel blocks forever, so changes are ignored
+	// unless publishing on change is enabled.
+	var changes <-chan struct{}
+	if o.publishOnChange && o.cache != nil {
+		changes = o.cache.changes
+	}
+
+	// Aggregate status per check of the last published datums.
+	published, lastReady := false, []bool{}
+
+	// Number of consecutive rounds that failed to publish, per sink.
+	failures := make([]int, len(o.sinks))
+
+	o.probes.markStarted()
+
+	// Used for rounds, so that they are not interrupted by a shutdown right
+	// away.
+	roundCtx, cancel := withShutdownTimeout(o.ctx, o.shutdownTimeout)
+	defer cancel()
+
 	for {
+		// A ready tick must not win against a shutdown that arrived while the
+		// previous round was in flight.
+		if o.ctx.Err() != nil {
+			return shutdownRounds(o)
+		}
+
 		select {
 		case <-o.ctx.Done():
-			o.log.Info("Received shutdown signal. Stopping.")
+			return shutdownRounds(o)
+		case <-changes:
+			// Changes can be frequent, so the scans are not logged.
+			scans := scanChecks(roundCtx, o, slog.New(slog.DiscardHandler))
+			o.exporter.observeScans(o.checks, scans)
+
+			if published && slices.Equal(readiness(scans), lastReady) {
+				continue
+			}
 
-			return nil
+			scanAttrs := []any{}
+			for i, scan := range scans {
+				scanAttrs = append(scanAttrs, slog.Any(o.checks[i].Name, scan))
+			}
+
+			o.log.Info(
+				"Aggregate status changed. Publishing metrics.",
+				slog.Group("scans", scanAttrs...),
+			)
+
+			// Only tick rounds count towards giving up, so that a flapping
+			// workload does not use up the failures during a short outage.
+			success, _ := publishScans(roundCtx, o, scans, nil)
+			o.probes.markRound(success)
+
+			if success {
+				published, lastReady = true, readiness(scans)
+			}
 		case <-ticker.C:
 			tickCount++
+			o.exporter.observeTick()
 			tickStart := time.Now()
 			tickLog := o.log.With(slog.Int("tickCount", tickCount))
 			tickLog.Info("Executing new tick round.")
 
-			scan := performScan(&performScanOptions{
-				ctx:     o.ctx,
-				log:     tickLog,
-				client:  o.kClient,
-				targets: o.targets,
-			})
+			scans := scanChecks(roundCtx, o, tickLog)
+			o.exporter.observeScans(o.checks, scans)
+
+			success, err := publishScans(roundCtx, o, scans, failures)
+			o.probes.markRound(success)
+
+			if err != nil {
+				return err
+			}
 
-			if err := updateMetric(&updateMetricOptions{
-				ctx:        o.ctx,
-				dry:        o.dry,
-				client:     o.cwClient,
-				namespace:  o.metric.Namespace,
-				name:       o.metric.Name,
-				dimensions: o.metric.Dimensions,
-				value:      scan.ready,
-			}); err != nil {
-				return fmt.Errorf("update metric: %v", err)
+			if success {
+				published, lastReady = true, readiness(scans)
 			}
 
 			tickDuration := time.Since(tickStart).Truncate(time.Millisecond)
@@ -292,15 +830,229 @@ func executeRounds(o *executeRoundsOptions) error {
 	}
 }
 
-// isFittingMode checks if the given and expected number of target instances is
-// fitting the mode. Two modes are supported: "AllOfThem" requires all replicas
-// to be ready. "AtLeastOne" requires at least one replica to be ready.
-func isFittingMode(mode string, got int, want int) bool {
-	switch mode {
+// checkFailures decides what happens after a fatal sink failed to publish.
+// Once the configured number of consecutive failures is reached, an error is
+// returned. Until then, the failure is only logged.
+func checkFailures(
+	o *executeRoundsOptions,
+	output outputSink,
+	failures int,
+	err error,
+) error {
+	if failures >= max(o.exitAfterFailures, 1) {
+		return fmt.Errorf(
+			"give up after %v consecutive failures of output %v: %v",
+			failures, output.name, err,
+		)
+	}
+
+	o.log.Error(
+		"Failed to publish. Trying again next round.",
+		slog.String("output", output.name),
+		slog.Any("error", err),
+		slog.Int("consecutiveFailures", failures),
+	)
+
+	return nil
+}
+
+// shutdownRounds is called once the context of executeRounds is canceled.
+// Depending on the configuration, it publishes the shutdown value with all
+// sinks or skips publishing altogether. Only failures of fatal sinks are
+// returned.
+func shutdownRounds(o *executeRoundsOptions) error {
+	if !o.shutdown.Publish {
+		o.log.Info("Received shutdown signal. Stopping without publishing.")
+
+		return nil
+	}
+
+	o.log.Info(
+		"Received shutdown signal. Publishing shutdown value and stopping.",
+		slog.Float64("value", o.shutdown.Value),
+	)
+
+	ctx, cancel := withShutdownTimeout(o.ctx, o.shutdownTimeout)
+	defer cancel()
+
+	errs := []error{}
+
+	for _, output := range o.sinks {
+		err := output.sink.publishShutdown(ctx, o.checks, o.shutdown.Value)
+		if err == nil {
+			continue
+		}
+
+		if !output.fatal {
+			o.log.Warn(
+				"Failed to publish shutdown value with best-effort output.",
+				slog.String("output", output.name),
+				slog.Any("error", err),
+			)
+
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf("output %v: %v", output.name, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Default time the round in flight and publishing the shutdown value may each
+// take after the shutdown signal. Together they stay below the default
+// termination grace period of 30 seconds of Kubernetes pods.
+const shutdownTimeout = 10 * time.Second
+
+// withShutdownTimeout returns a context that is not canceled together with the
+// given context. Instead, it is canceled once the given timeout has passed
+// after the given context is done. A timeout of zero disables this.
+func withShutdownTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	shutdownCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if timeout <= 0 {
+		return shutdownCtx, cancel
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancel()
+		case <-shutdownCtx.Done():
+		}
+	})
+
+	return shutdownCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// scanChecks scans the targets of all checks one check after another. It
+// returns one scan per check, in the order of the checks.
+func scanChecks(
+	ctx context.Context,
+	o *executeRoundsOptions,
+	log *slog.Logger,
+) []scan {
+	scans := []scan{}
+
+	for _, check := range o.checks {
+		scans = append(scans, performScan(&performScanOptions{
+			ctx:           ctx,
+			log:           log.With(slog.String("check", check.Name)),
+			client:        o.kClient,
+			dynamicClient: o.dClient,
+			cache:         o.cache,
+			workers:       o.workers,
+			timeout:       newTargetTimeout(o.seconds),
+			targets:       check.Targets,
+		}))
+	}
+
+	return scans
+}
+
+// readiness returns the aggregate status of the given scans.
+func readiness(scans []scan) []bool {
+	ready := []bool{}
+	for _, scan := range scans {
+		ready = append(ready, scan.ready)
+	}
+
+	return ready
+}
+
+// publishScans publishes the given scans, one scan per check, with all sinks
+// one after another. The given consecutive failures per sink are updated.
+// Failures of best-effort sinks are only logged. The returned flag is false
+// if any fatal sink failed. An error is returned once a fatal sink failed too
+// many consecutive times.
+//
+// Nil failures are neither updated nor checked and all failures are only
+// logged. This is used for publishes triggered by changes, as only failed
+// tick rounds count towards giving up.
+func publishScans(
+	ctx context.Context,
+	o *executeRoundsOptions,
+	scans []scan,
+	failures []int,
+) (bool, error) {
+	success := true
+
+	for i, output := range o.sinks {
+		err := output.sink.publish(ctx, o.checks, scans)
+		if err == nil {
+			if failures != nil {
+				failures[i] = 0
+			}
+
+			continue
+		}
+
+		if failures == nil {
+			success = success && !output.fatal
+
+			o.log.Warn(
+				"Failed to publish change. Trying again next round.",
+				slog.String("output", output.name),
+				slog.Any("error", err),
+			)
+
+			continue
+		}
+
+		failures[i]++
+
+		if !output.fatal {
+			o.log.Warn(
+				"Failed to publish with best-effort output.",
+				slog.String("output", output.name),
+				slog.Any("error", err),
+				slog.Int("consecutiveFailures", failures[i]),
+			)
+
+			continue
+		}
+
+		success = false
+
+		if err := checkFailures(o, output, failures[i], err); err != nil {
+			return false, err
+		}
+	}
+
+	return success, nil
+}
+
+// isFittingMode checks if the ready and desired replicas in the given result
+// are fitting the mode. "AllOfThem" requires all desired replicas to be ready.
+// "AtLeastOne" requires at least one replica to be ready unless no replicas
+// are desired. "Percentage" requires at least the threshold percentage of
+// desired replicas to be ready. "MinReady" requires at least the given number
+// of replicas to be ready. In the other modes, the minimum is an additional
+// floor, so that replicas that disappeared altogether are noticed as well.
+func isFittingMode(result result) bool {
+	ready, desired := result.replicas.ready, result.replicas.desired
+
+	if ready < result.minReady {
+		return false
+	}
+
+	switch result.mode {
 	case modeAllOfThem:
-		return got == want
+		return ready >= desired
 	case modeAtLeastOne:
-		return want == 0 || got > 0
+		return desired == 0 || ready > 0
+	case modePercentage:
+		return ready*100 >= result.threshold*desired
+	case modeMinReady:
+		return true
 	default:
 		return false
 	}
@@ -321,6 +1073,27 @@ type scan struct {
 
 	// List of all results by target. One result per target.
 	results []result
+
+	// Start time of the scan. Used as timestamp of published datums.
+	start time.Time
+
+	// Duration of the scan of all targets.
+	duration time.Duration
+}
+
+// LogValue implements slog.LogValuer so that scans are logged with all fields
+// regardless of the handler in use.
+func (s scan) LogValue() slog.Value {
+	results := make([]slog.Attr, 0, len(s.results))
+	for i, result := range s.results {
+		results = append(results, slog.Any(strconv.Itoa(i), result))
+	}
+
+	return slog.GroupValue(
+		slog.Bool("success", s.success),
+		slog.Bool("ready", s.ready),
+		slog.Attr{Key: "results", Value: slog.GroupValue(results...)},
+	)
 }
 
 // result holds information regarding a single target scan in a scan. Based on
@@ -334,21 +1107,70 @@ type result struct {
 	// Shows if the target is ready or not according to configured mode.
 	ready bool
 
-	// Type of the scanned target. For example "Deployment" or "StatefulSet".
+	// Type of the scanned target. For example "Deployment" or "CronJob".
 	kind string
 
-	// namespace of the scanned target. For example "kube-system".
+	// namespace of the scanned target. For example "kube-system". If the
+	// target uses a namespace selector and the result does not belong to a
+	// single object, this is the namespace selector.
 	namespace string
 
-	// name of the scanned target. For example "prometheus".
+	// name of the scanned target. For example "prometheus". If the target
+	// uses a label selector and the result does not belong to a single
+	// object, this is the label selector.
 	name string
 
 	// mode of the scanned target. For example "AllOfThem".
 	mode string
 
-	// Ready information.
-	got  int
-	want int
+	// Mode parameters of the scanned target. Only relevant for the modes
+	// "Percentage" and "MinReady" respectively.
+	threshold int
+	minReady  int
+
+	// Replica counts of the scanned target. Used for mode evaluation.
+	replicas replicas
+
+	// reason explains why the target is not ready. Empty if ready or if the
+	// reason is not known.
+	reason string
+}
+
+// LogValue implements slog.LogValuer so that results are logged with all
+// fields regardless of the handler in use.
+func (r result) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("success", r.success),
+		slog.Bool("ready", r.ready),
+		slog.String("kind", r.kind),
+		slog.String("namespace", r.namespace),
+		slog.String("name", r.name),
+		slog.String("mode", r.mode),
+		slog.Int("threshold", r.threshold),
+		slog.Int("minReady", r.minReady),
+		slog.Int("desiredReplicas", r.replicas.desired),
+		slog.Int("readyReplicas", r.replicas.ready),
+		slog.Int("availableReplicas", r.replicas.available),
+		slog.Int("updatedReplicas", r.replicas.updated),
+		slog.String("reason", r.reason),
+	)
+}
+
+// replicas holds the replica counts of a scanned target. What counts as a
+// replica depends on the kind. For pods, every matched pod is one replica.
+type replicas struct {
+	// Number of replicas that should exist according to the spec.
+	desired int
+
+	// Number of replicas that are ready.
+	ready int
+
+	// Number of replicas that have been ready for at least the minimum ready
+	// seconds of the target.
+	available int
+
+	// Number of replicas that run the latest revision of the target.
+	updated int
 }
 
 // performScanOptions holds the input for the performScan function.
@@ -359,94 +1181,81 @@ type performScanOptions struct {
 	// Kubernetes client.
 	client kube.Interface
 
+	// Dynamic Kubernetes client. Used for custom resources.
+	dynamicClient kubedynamic.Interface
+
+	// Local watch cache. If set, objects are read from it instead of the
+	// Kubernetes API.
+	cache *informerCache
+
+	// Number of targets scanned concurrently. At least one target is always
+	// scanned.
+	workers int
+
+	// Maximum duration of a single target scan. Zero disables the timeout.
+	timeout time.Duration
+
 	// Targets to scan.
 	targets []target
 }
 
+// Fraction of the interval a single target scan may take.
+const targetTimeoutDivisor = 2
+
+// newTargetTimeout returns the timeout for a single target scan. It is a
+// fraction of the interval, so that a round with slow targets still finishes
+// before the next tick.
+func newTargetTimeout(seconds int) time.Duration {
+	return time.Duration(seconds) * time.Second / targetTimeoutDivisor
+}
+
 // performScan queries the Kubernetes API for all given targets and checks
-// condition status of the respective resources. The results of individual
-// target scans are collected in the returned struct. Errors are logged and then
+// condition status of the respective resources. Targets are scanned
+// concurrently. The results of individual target scans are collected in the
+// returned struct in the order of the targets. Errors are logged and then
 // swallowed.
 func performScan(o *performScanOptions) scan {
-	scan := scan{success: true, ready: true, results: nil}
-
-	for _, target := range o.targets {
-		result := result{
-			success:   true,
-			ready:     true,
-			kind:      target.Kind,
-			namespace: target.Namespace,
-			name:      target.Name,
-			mode:      target.Mode,
-			got:       0,
-			want:      0,
-		}
-
-		switch target.Kind {
-		case kindDaemonSet:
-			daemonSet, err := o.client.AppsV1().
-				DaemonSets(target.Namespace).
-				Get(o.ctx, target.Name, kubemetav1.GetOptions{})
-			if err != nil {
-				o.log.Error(
-					"Failed to query Kubernetes API.",
-					slog.Any("error", err),
-				)
+	scan := scan{
+		success:  true,
+		ready:    true,
+		results:  nil,
+		start:    time.Now(),
+		duration: 0,
+	}
 
-				scan.success, scan.ready = false, false
-				result.success, result.ready = false, false
-			} else {
-				result.got = int(daemonSet.Status.DesiredNumberScheduled)
-				result.want = int(daemonSet.Status.NumberReady)
-			}
-		case kindDeployment:
-			deployment, err := o.client.AppsV1().
-				Deployments(target.Namespace).
-				Get(o.ctx, target.Name, kubemetav1.GetOptions{})
-			if err != nil {
-				o.log.Error(
-					"Failed to query Kubernetes API.",
-					slog.Any("error", err),
-				)
+	targetResults := make([][]result, len(o.targets))
+	workers := make(chan struct{}, max(o.workers, 1))
 
-				scan.success, scan.ready = false, false
-				result.success, result.ready = false, false
-			} else {
-				result.got = int(deployment.Status.Replicas)
-				result.want = int(deployment.Status.ReadyReplicas)
-			}
-		case kindStatefulSet:
-			statefulSet, err := o.client.AppsV1().
-				StatefulSets(target.Namespace).
-				Get(o.ctx, target.Name, kubemetav1.GetOptions{})
-			if err != nil {
-				o.log.Error(
-					"Failed to query Kubernetes API.",
-					slog.Any("error", err),
-				)
+	var wg sync.WaitGroup
 
-				scan.success, scan.ready = false, false
-				result.success, result.ready = false, false
-			} else {
-				result.got = int(statefulSet.Status.Replicas)
-				result.want = int(statefulSet.Status.ReadyReplicas)
+	for i, target := range o.targets {
+		workers <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-workers }()
+
+			targetResults[i] = scanTargetWithTimeout(o, target)
+		})
+	}
+
+	wg.Wait()
+
+	for _, results := range targetResults {
+		for _, result := range results {
+			if !result.success {
+				scan.success = false
 			}
-		default:
-			scan.success, scan.ready = false, false
-			result.success, result.ready = false, false
-		}
 
-		if result.success {
-			result.ready = isFittingMode(target.Mode, result.got, result.want)
-		}
+			if !result.ready {
+				scan.ready = false
+			}
 
-		if !result.ready {
-			scan.ready = false
+			scan.results = append(scan.results, result)
 		}
-
-		scan.results = append(scan.results, result)
 	}
 
+	scan.duration = time.Since(scan.start)
+
 	if scan.success && scan.ready {
 		o.log.Debug("Done with scan. All looking good.", slog.Any("scan", scan))
 	} else {
@@ -459,70 +1268,2642 @@ func performScan(o *performScanOptions) scan {
 	return scan
 }
 
-// cwPutMetricDataAPI defines the interface for the PutMetricData function.
-// We use this interface to test the function using a mocked service.
-type cwPutMetricDataAPI interface {
-	PutMetricData(ctx context.Context,
-		params *cw.PutMetricDataInput,
-		optFns ...func(*cw.Options),
-	) (*cw.PutMetricDataOutput, error)
+// scanTargetWithTimeout scans a single target with the configured timeout.
+// If the timeout is exceeded, the target results in a single failed result,
+// regardless of what the scan returned.
+func scanTargetWithTimeout(o *performScanOptions, target target) []result {
+	if o.timeout <= 0 {
+		return scanTarget(o, target)
+	}
+
+	ctx, cancel := context.WithTimeout(o.ctx, o.timeout)
+	defer cancel()
+
+	targetOptions := *o
+	targetOptions.ctx = ctx
+
+	results := scanTarget(&targetOptions, target)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		o.log.Error(
+			"Scan of target timed out.",
+			slog.Any("target", target),
+			slog.String("timeout", o.timeout.String()),
+		)
+
+		timedOut := newResultTemplate(target)
+		timedOut.success, timedOut.ready = false, false
+		timedOut.reason = fmt.Sprintf("scan timed out after %v", o.timeout)
+
+		return []result{timedOut}
+	}
+
+	return results
 }
 
-// updateMetricOptions holds the input for the updateMetric function.
-type updateMetricOptions struct {
-	ctx context.Context
-	dry bool
+// newResultTemplate returns the result used as a template for every result
+// of the given target. Namespace and name are replaced with the ones of the
+// matched objects where possible.
+func newResultTemplate(target target) result {
+	return result{
+		success:   true,
+		ready:     true,
+		kind:      target.Kind,
+		namespace: cmp.Or(target.Namespace, target.NamespaceSelector),
+		name:      cmp.Or(target.Name, target.LabelSelector),
+		mode:      target.Mode,
+		threshold: target.Threshold,
+		minReady:  target.MinReady,
+		replicas:  replicas{desired: 0, ready: 0, available: 0, updated: 0},
+		reason:    "",
+	}
+}
 
-	// CloudWatch client with required interface.
-	client cwPutMetricDataAPI
+// scanTarget scans a single target. Targets that select objects by label
+// selector are expanded and every matched object is evaluated on its own,
+// resulting in one result per object. Pods and nodes are the exception, they
+// are always evaluated together as a single result.
+func scanTarget(o *performScanOptions, target target) []result {
+	template := newResultTemplate(target)
 
-	// Namespace and name of the CloudWatch metric to update.
-	namespace string
-	name      string
+	namespaces, err := selectNamespaces(o, target)
+	if err != nil {
+		o.log.Error(
+			"Failed to query Kubernetes API.",
+			slog.Any("error", err),
+		)
+
+		template.success, template.ready = false, false
+		template.reason = err.Error()
+
+		return []result{template}
+	}
+
+	results := []result{}
 
-	// Dimensions of the CloudWatch metric to update.
-	dimensions []dimension
+	for _, namespace := range namespaces {
+		namespaceResults, err := queryObjects(o, target, namespace, template)
+		if err != nil {
+			o.log.Error(
+				"Failed to query Kubernetes API.",
+				slog.Any("error", err),
+			)
+
+			template.success, template.ready = false, false
+			template.reason = err.Error()
+
+			return []result{template}
+		}
+
+		results = append(results, namespaceResults...)
+	}
+
+	// The watch cache returns objects in random order.
+	slices.SortStableFunc(results, func(a, b result) int {
+		return cmp.Or(
+			strings.Compare(a.namespace, b.namespace),
+			strings.Compare(a.name, b.name),
+		)
+	})
+
+	if len(results) == 0 {
+		o.log.Warn(
+			"No objects matched target.",
+			slog.Any("target", target),
+		)
+
+		template.ready = target.OnNoMatch == noMatchReady
+		if !template.ready {
+			template.reason = "no objects matched"
+		}
+
+		return []result{template}
+	}
 
-	// Value of the CloudWatch metric to update.
-	value bool
+	if target.Kind == kindPod || target.Kind == kindNode {
+		aggregated := template
+		for _, result := range results {
+			aggregated.replicas.desired += result.replicas.desired
+			aggregated.replicas.updated += result.replicas.updated
+
+			// Objects with a reason, for example an expression that is not
+			// satisfied, do not count as ready.
+			if result.reason == "" {
+				aggregated.replicas.ready += result.replicas.ready
+				aggregated.replicas.available += result.replicas.available
+			}
+		}
+
+		results = []result{aggregated}
+	}
+
+	for i := range results {
+		if results[i].reason != "" {
+			results[i].ready = false
+		} else if !isFittingMode(results[i]) {
+			results[i].ready = false
+			results[i].reason = "replicas not fitting mode"
+		}
+	}
+
+	return results
 }
 
-// updateMetric updates a CloudWatch metric using PutMetricData.
-func updateMetric(o *updateMetricOptions) error {
-	if o.dimensions == nil {
-		o.dimensions = []dimension{}
+// selectNamespaces returns the namespaces of the given target. Either the
+// namespace explicitly configured or all namespaces matching the namespace
+// selector. Cluster-scoped targets have a single empty namespace.
+func selectNamespaces(o *performScanOptions, target target) ([]string, error) {
+	if target.NamespaceSelector == "" {
+		return []string{target.Namespace}, nil
 	}
 
-	metricValue := 0.0
-	if o.value {
-		metricValue = 1.0
+	client := o.client.CoreV1().Namespaces()
+	source := newAPISource(
+		client.Get, client.List,
+		func(l *kubecorev1.NamespaceList) []kubecorev1.Namespace {
+			return l.Items
+		},
+	)
+
+	if o.cache != nil {
+		lister := o.cache.factory(namespaceScope(target)).Core().V1().
+			Namespaces().Lister()
+
+		source = newCacheSource(lister.Get, lister.List)
 	}
 
-	metricDimensions := []cwtypes.Dimension{}
-	for _, configDimension := range o.dimensions {
-		metricDimensions = append(metricDimensions, cwtypes.Dimension{
-			Name:  aws.String(configDimension.Name),
-			Value: aws.String(configDimension.Value),
-		})
+	namespaces, err := source.list(o.ctx, target.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("list namespaces: %v", err)
+	}
+
+	names := []string{}
+	for _, namespace := range namespaces {
+		names = append(names, namespace.Name)
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// queryObjects queries the Kubernetes API for the objects of the given target
+// in the given namespace. It returns one result per object, based on the
+// given template.
+func queryObjects(
+	o *performScanOptions,
+	target target,
+	namespace string,
+	template result,
+) ([]result, error) {
+	newResult := func(object kubemetav1.Object, replicas replicas) result {
+		result := template
+		result.namespace, result.name = object.GetNamespace(), object.GetName()
+		result.replicas = replicas
+
+		if target.program != nil {
+			result.reason = evaluateExpression(target.program, object)
+		}
+
+		return result
+	}
+
+	if target.APIVersion != "" {
+		return queryCustomObjects(o, target, namespace, template)
+	}
+
+	// Informer factory of the target. Only set with the local watch cache.
+	var factory kubeinformers.SharedInformerFactory
+	if o.cache != nil {
+		factory = o.cache.factory(objectScope(target))
 	}
 
-	if !o.dry {
-		_, err := o.client.PutMetricData(
-			o.ctx,
-			&cw.PutMetricDataInput{
-				Namespace: aws.String(o.namespace),
-				MetricData: []cwtypes.MetricDatum{{
-					MetricName: aws.String(o.name),
-					Unit:       cwtypes.StandardUnitNone,
-					Value:      aws.Float64(metricValue),
-					Dimensions: metricDimensions,
-				}},
+	results := []result{}
+
+	switch target.Kind {
+	case kindDaemonSet:
+		client := o.client.AppsV1().DaemonSets(namespace)
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubeappsv1.DaemonSetList) []kubeappsv1.DaemonSet {
+				return l.Items
+			},
+		)
+
+		if o.cache != nil {
+			lister := factory.Apps().V1().DaemonSets().Lister().
+				DaemonSets(namespace)
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		daemonSets, err := fetchObjects(o.ctx, target, source)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, daemonSet := range daemonSets {
+			results = append(results, newResult(
+				&daemonSet,
+				replicas{
+					desired:   int(daemonSet.Status.DesiredNumberScheduled),
+					ready:     int(daemonSet.Status.NumberReady),
+					available: int(daemonSet.Status.NumberAvailable),
+					updated:   int(daemonSet.Status.UpdatedNumberScheduled),
+				},
+			))
+		}
+	case kindDeployment:
+		client := o.client.AppsV1().Deployments(namespace)
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubeappsv1.DeploymentList) []kubeappsv1.Deployment {
+				return l.Items
+			},
+		)
+
+		if o.cache != nil {
+			lister := factory.Apps().V1().Deployments().Lister().
+				Deployments(namespace)
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		deployments, err := fetchObjects(o.ctx, target, source)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, deployment := range deployments {
+			result := newResult(
+				&deployment,
+				replicas{
+					desired:   specReplicas(deployment.Spec.Replicas),
+					ready:     int(deployment.Status.ReadyReplicas),
+					available: int(deployment.Status.AvailableReplicas),
+					updated:   int(deployment.Status.UpdatedReplicas),
+				},
+			)
+
+			if target.CheckConditions {
+				result.reason = cmp.Or(
+					checkDeploymentConditions(&deployment), result.reason,
+				)
+			}
+
+			results = append(results, result)
+		}
+	case kindStatefulSet:
+		client := o.client.AppsV1().StatefulSets(namespace)
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubeappsv1.StatefulSetList) []kubeappsv1.StatefulSet {
+				return l.Items
+			},
+		)
+
+		if o.cache != nil {
+			lister := factory.Apps().V1().StatefulSets().Lister().
+				StatefulSets(namespace)
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		statefulSets, err := fetchObjects(o.ctx, target, source)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, statefulSet := range statefulSets {
+			results = append(results, newResult(
+				&statefulSet,
+				replicas{
+					desired:   specReplicas(statefulSet.Spec.Replicas),
+					ready:     int(statefulSet.Status.ReadyReplicas),
+					available: int(statefulSet.Status.AvailableReplicas),
+					updated:   int(statefulSet.Status.UpdatedReplicas),
+				},
+			))
+		}
+	case kindPod:
+		client := o.client.CoreV1().Pods(namespace)
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubecorev1.PodList) []kubecorev1.Pod {
+				return l.Items
+			},
+		)
+
+		if o.cache != nil {
+			lister := factory.Core().V1().Pods().Lister().
+				Pods(namespace)
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		pods, err := fetchObjects(o.ctx, target, source)
+		if err != nil {
+			return nil, err
+		}
+
+		// Pods have no rollout of their own, so every pod counts as
+		// updated and every ready pod counts as available.
+		for _, pod := range pods {
+			ready := 0
+			if isPodReady(&pod) {
+				ready = 1
+			}
+
+			results = append(results, newResult(
+				&pod,
+				replicas{
+					desired:   1,
+					ready:     ready,
+					available: ready,
+					updated:   1,
+				},
+			))
+		}
+	case kindJob:
+		client := o.client.BatchV1().Jobs(namespace)
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubebatchv1.JobList) []kubebatchv1.Job {
+				return l.Items
+			},
+		)
+
+		if o.cache != nil {
+			lister := factory.Batch().V1().Jobs().Lister().
+				Jobs(namespace)
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		jobs, err := fetchObjects(o.ctx, target, source)
+		if err != nil {
+			return nil, err
+		}
+
+		// Jobs have no rollout of their own, so every completion counts as
+		// updated and every succeeded completion counts as available.
+		for _, job := range jobs {
+			completions := specReplicas(job.Spec.Completions)
+
+			result := newResult(
+				&job,
+				replicas{
+					desired:   completions,
+					ready:     int(job.Status.Succeeded),
+					available: int(job.Status.Succeeded),
+					updated:   completions,
+				},
+			)
+			result.reason = cmp.Or(checkJobConditions(&job), result.reason)
+
+			results = append(results, result)
+		}
+	case kindCronJob:
+		client := o.client.BatchV1().CronJobs(namespace)
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubebatchv1.CronJobList) []kubebatchv1.CronJob {
+				return l.Items
+			},
+		)
+
+		if o.cache != nil {
+			lister := factory.Batch().V1().CronJobs().Lister().
+				CronJobs(namespace)
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		cronJobs, err := fetchObjects(o.ctx, target, source)
+		if err != nil {
+			return nil, err
+		}
+
+		// A cron job counts as a single replica that is ready if the last
+		// successful run is recent enough.
+		for _, cronJob := range cronJobs {
+			reason := checkCronJobSchedule(&cronJob, target.MaxAge)
+
+			ready := 0
+			if reason == "" {
+				ready = 1
+			}
+
+			result := newResult(
+				&cronJob,
+				replicas{
+					desired:   1,
+					ready:     ready,
+					available: ready,
+					updated:   1,
+				},
+			)
+			result.reason = cmp.Or(reason, result.reason)
+
+			results = append(results, result)
+		}
+	case kindNode:
+		client := o.client.CoreV1().Nodes()
+
+		source := newAPISource(
+			client.Get, client.List,
+			func(l *kubecorev1.NodeList) []kubecorev1.Node {
+				return l.Items
 			},
 		)
+
+		if o.cache != nil {
+			lister := factory.Core().V1().Nodes().Lister()
+
+			source = newCacheSource(lister.Get, lister.List)
+		}
+
+		nodes, err := fetchObjects(o.ctx, target, source)
 		if err != nil {
-			return fmt.Errorf("update metric: %v", err)
+			return nil, err
+		}
+
+		// Nodes are treated like pods, every ready node counts as an
+		// available replica.
+		for _, node := range nodes {
+			ready := 0
+			if isNodeReady(&node) {
+				ready = 1
+			}
+
+			results = append(results, newResult(
+				&node,
+				replicas{
+					desired:   1,
+					ready:     ready,
+					available: ready,
+					updated:   1,
+				},
+			))
 		}
+	default:
+		return nil, fmt.Errorf("unsupported kind: %v", target.Kind)
 	}
 
-	return nil
+	return results, nil
+}
+
+// queryCustomObjects queries the Kubernetes API for the custom resources of
+// the given target in the given namespace using the dynamic client. Every
+// object counts as a single replica that is ready if the configured condition
+// has the expected status and the configured expression is satisfied. If
+// JSONPath templates are configured, replicas are extracted from the object.
+func queryCustomObjects(
+	o *performScanOptions,
+	target target,
+	namespace string,
+	template result,
+) ([]result, error) {
+	groupVersion, err := kubeschema.ParseGroupVersion(target.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("parse apiVersion: %v", err)
+	}
+
+	resource := groupVersion.WithResource(target.Resource)
+	client := o.dynamicClient.Resource(resource).Namespace(namespace)
+
+	source := newAPISource(
+		func(
+			ctx context.Context,
+			name string,
+			options kubemetav1.GetOptions,
+		) (*kubeunstructured.Unstructured, error) {
+			return client.Get(ctx, name, options)
+		},
+		client.List,
+		func(
+			l *kubeunstructured.UnstructuredList,
+		) []kubeunstructured.Unstructured {
+			return l.Items
+		},
+	)
+
+	if o.cache != nil {
+		genericLister := o.cache.dynamicFactory(objectScope(target)).
+			ForResource(resource).Lister()
+
+		// Cluster-scoped objects are stored without namespace, so they are
+		// only found by the lister itself.
+		lister := unstructuredLister{lister: genericLister}
+		if namespace != "" {
+			lister.lister = genericLister.ByNamespace(namespace)
+		}
+
+		source = newCacheSource(lister.Get, lister.List)
+	}
+
+	objects, err := fetchObjects(o.ctx, target, source)
+	if err != nil {
+		return nil, err
+	}
+
+	conditionType, conditionStatus, _ := splitCondition(target.Condition)
+
+	results := []result{}
+
+	for _, object := range objects {
+		reason := ""
+		if conditionType != "" {
+			reason = checkCustomCondition(
+				&object, conditionType, conditionStatus,
+			)
+		}
+
+		ready := 0
+		if reason == "" {
+			ready = 1
+		}
+
+		objectReplicas := replicas{
+			desired:   1,
+			ready:     ready,
+			available: ready,
+			updated:   1,
+		}
+
+		if target.JSONPath.Ready != "" {
+			extracted, err := extractReplicas(&object, target.JSONPath)
+			if err != nil {
+				reason = cmp.Or(reason, err.Error())
+			} else {
+				objectReplicas = extracted
+			}
+		}
+
+		result := template
+		result.namespace, result.name = object.GetNamespace(), object.GetName()
+		result.replicas = objectReplicas
+		result.reason = reason
+
+		if target.program != nil && reason == "" {
+			result.reason = evaluateExpression(target.program, &object)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// extractReplicas extracts replica counts from the given custom resource with
+// the given JSONPath templates. If no desired template is given, the ready
+// template must extract a boolean and the object counts as a single replica.
+// Missing ready values count as zero or false.
+func extractReplicas(
+	object *kubeunstructured.Unstructured,
+	paths jsonPath,
+) (replicas, error) {
+	if paths.Desired == "" {
+		value, err := extractValue(object, paths.Ready)
+		if err != nil {
+			return replicas{}, fmt.Errorf("extract ready: %v", err)
+		}
+
+		healthy, err := strconv.ParseBool(cmp.Or(value, "false"))
+		if err != nil {
+			return replicas{}, fmt.Errorf("extract ready: %v", err)
+		}
+
+		ready := 0
+		if healthy {
+			ready = 1
+		}
+
+		return replicas{
+			desired:   1,
+			ready:     ready,
+			available: ready,
+			updated:   1,
+		}, nil
+	}
+
+	value, err := extractValue(object, paths.Ready)
+	if err != nil {
+		return replicas{}, fmt.Errorf("extract ready: %v", err)
+	}
+
+	ready, err := strconv.Atoi(cmp.Or(value, "0"))
+	if err != nil {
+		return replicas{}, fmt.Errorf("extract ready: %v", err)
+	}
+
+	value, err = extractValue(object, paths.Desired)
+	if err != nil {
+		return replicas{}, fmt.Errorf("extract desired: %v", err)
+	}
+
+	if value == "" {
+		return replicas{}, fmt.Errorf("extract desired: no value")
+	}
+
+	desired, err := strconv.Atoi(value)
+	if err != nil {
+		return replicas{}, fmt.Errorf("extract desired: %v", err)
+	}
+
+	return replicas{
+		desired:   desired,
+		ready:     ready,
+		available: ready,
+		updated:   desired,
+	}, nil
+}
+
+// extractValue extracts a single value from the given custom resource with
+// the given JSONPath template and returns it as string. Missing values result
+// in an empty string.
+func extractValue(
+	object *kubeunstructured.Unstructured,
+	template string,
+) (string, error) {
+	path := kubejsonpath.New("").AllowMissingKeys(true)
+	if err := path.Parse(template); err != nil {
+		return "", fmt.Errorf("parse: %v", err)
+	}
+
+	results, err := path.FindResults(object.Object)
+	if err != nil {
+		return "", fmt.Errorf("find: %v", err)
+	}
+
+	values := []string{}
+
+	for _, result := range results {
+		for _, value := range result {
+			values = append(values, fmt.Sprint(value.Interface()))
+		}
+	}
+
+	if len(values) > 1 {
+		return "", fmt.Errorf("multiple values: %v", values)
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	return values[0], nil
+}
+
+// checkCustomCondition checks if the given custom resource has a status
+// condition of the given type with the given status. It returns the reason
+// why the object is considered unhealthy or an empty string if it is healthy.
+func checkCustomCondition(
+	object *kubeunstructured.Unstructured,
+	conditionType string,
+	conditionStatus string,
+) string {
+	conditions, _, err := kubeunstructured.NestedSlice(
+		object.Object, "status", "conditions",
+	)
+	if err != nil {
+		return fmt.Sprintf("read conditions: %v", err)
+	}
+
+	for _, item := range conditions {
+		condition, ok := item.(map[string]any)
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+
+		if condition["status"] != conditionStatus {
+			return fmt.Sprintf(
+				"condition %v is %v", conditionType, condition["status"],
+			)
+		}
+
+		return ""
+	}
+
+	return fmt.Sprintf("condition %v missing", conditionType)
+}
+
+// evaluateExpression evaluates the given compiled expression against the
+// given object. It returns the reason why the object is considered unhealthy
+// or an empty string if the expression evaluates to true.
+func evaluateExpression(program cel.Program, object any) string {
+	content, err := kuberuntime.DefaultUnstructuredConverter.
+		ToUnstructured(object)
+	if err != nil {
+		return fmt.Sprintf("convert object: %v", err)
+	}
+
+	value, _, err := program.Eval(map[string]any{"object": content})
+	if err != nil {
+		return fmt.Sprintf("evaluate expression: %v", err)
+	}
+
+	if value.Value() != true {
+		return "expression not satisfied"
+	}
+
+	return ""
+}
+
+// specReplicas returns the number of replicas or completions requested in a
+// spec. Kubernetes defaults the number to 1 if it is not set.
+func specReplicas(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+
+	return int(*replicas)
+}
+
+// objectSource gets and lists objects of a single kind, either from the
+// Kubernetes API or from the local watch cache.
+type objectSource[T any] struct {
+	get  func(ctx context.Context, name string) (*T, error)
+	list func(ctx context.Context, selector string) ([]T, error)
+}
+
+// newAPISource creates an object source that queries the Kubernetes API with
+// the given functions of a typed or dynamic client.
+func newAPISource[T any, L any](
+	get func(context.Context, string, kubemetav1.GetOptions) (*T, error),
+	list func(context.Context, kubemetav1.ListOptions) (*L, error),
+	items func(*L) []T,
+) objectSource[T] {
+	return objectSource[T]{
+		get: func(ctx context.Context, name string) (*T, error) {
+			return get(ctx, name, kubemetav1.GetOptions{})
+		},
+		list: func(ctx context.Context, selector string) ([]T, error) {
+			objects, err := list(ctx, kubemetav1.ListOptions{
+				LabelSelector: selector,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return items(objects), nil
+		},
+	}
+}
+
+// newCacheSource creates an object source that reads from the local watch
+// cache with the given functions of a lister.
+func newCacheSource[T any](
+	get func(string) (*T, error),
+	list func(kubelabels.Selector) ([]*T, error),
+) objectSource[T] {
+	return objectSource[T]{
+		get: func(_ context.Context, name string) (*T, error) {
+			return get(name)
+		},
+		list: func(_ context.Context, selector string) ([]T, error) {
+			parsed, err := kubelabels.Parse(selector)
+			if err != nil {
+				return nil, fmt.Errorf("parse selector: %v", err)
+			}
+
+			objects, err := list(parsed)
+			if err != nil {
+				return nil, err
+			}
+
+			items := make([]T, 0, len(objects))
+			for _, object := range objects {
+				items = append(items, *object)
+			}
+
+			return items, nil
+		},
+	}
+}
+
+// unstructuredLister adapts a lister of the dynamic watch cache to return
+// unstructured objects instead of generic runtime objects. The lister is
+// either namespaced or, for cluster-scoped objects, the lister itself.
+type unstructuredLister struct {
+	lister kubecache.GenericNamespaceLister
+}
+
+// Get returns the object with the given name from the watch cache.
+func (l unstructuredLister) Get(
+	name string,
+) (*kubeunstructured.Unstructured, error) {
+	object, err := l.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUnstructured(object)
+}
+
+// List returns all objects matching the given selector from the watch cache.
+func (l unstructuredLister) List(
+	selector kubelabels.Selector,
+) ([]*kubeunstructured.Unstructured, error) {
+	objects, err := l.lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*kubeunstructured.Unstructured, 0, len(objects))
+
+	for _, object := range objects {
+		item, err := toUnstructured(object)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// toUnstructured asserts that the given object from the dynamic watch cache
+// is unstructured.
+func toUnstructured(
+	object kuberuntime.Object,
+) (*kubeunstructured.Unstructured, error) {
+	item, ok := object.(*kubeunstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type: %T", object)
+	}
+
+	return item, nil
+}
+
+// fetchObjects gets the object with the name of the given target from the
+// given source. If the target has no name, all objects matching the label
+// selector are listed.
+func fetchObjects[T any](
+	ctx context.Context,
+	target target,
+	source objectSource[T],
+) ([]T, error) {
+	if target.Name != "" {
+		object, err := source.get(ctx, target.Name)
+		if err != nil {
+			return nil, fmt.Errorf("get %v: %v", target.Kind, err)
+		}
+
+		return []T{*object}, nil
+	}
+
+	objects, err := source.list(ctx, target.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("list %v: %v", target.Kind, err)
+	}
+
+	return objects, nil
+}
+
+// Reason of the "Progressing" condition set by the deployment controller if a
+// rollout does not make progress within the progress deadline.
+const reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// checkDeploymentConditions checks if the given deployment is healthy based on
+// its conditions and generation. It returns the reason why the deployment is
+// unhealthy or an empty string if it is healthy.
+func checkDeploymentConditions(deployment *kubeappsv1.Deployment) string {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return fmt.Sprintf(
+			"observed generation %v behind generation %v",
+			deployment.Status.ObservedGeneration,
+			deployment.Generation,
+		)
+	}
+
+	available := false
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == kubeappsv1.DeploymentProgressing &&
+			condition.Reason == reasonProgressDeadlineExceeded {
+			return "progress deadline exceeded"
+		}
+
+		if condition.Type == kubeappsv1.DeploymentAvailable {
+			available = condition.Status == kubecorev1.ConditionTrue
+		}
+	}
+
+	if !available {
+		return "not available"
+	}
+
+	return ""
+}
+
+// checkJobConditions checks if the given job has failed. It returns the reason
+// why the job failed or an empty string if it has not failed.
+func checkJobConditions(job *kubebatchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == kubebatchv1.JobFailed &&
+			condition.Status == kubecorev1.ConditionTrue {
+			return fmt.Sprintf("failed: %v", condition.Reason)
+		}
+	}
+
+	return ""
+}
+
+// checkCronJobSchedule checks if the given cron job has succeeded within the
+// given maximum age. It returns the reason why the cron job is considered
+// unhealthy or an empty string if it is healthy.
+func checkCronJobSchedule(
+	cronJob *kubebatchv1.CronJob,
+	maxAge time.Duration,
+) string {
+	if cronJob.Status.LastSuccessfulTime == nil {
+		return "no successful run"
+	}
+
+	age := time.Since(cronJob.Status.LastSuccessfulTime.Time)
+	if age > maxAge {
+		return fmt.Sprintf(
+			"last successful run %v ago exceeds max age %v",
+			age.Truncate(time.Second), maxAge,
+		)
+	}
+
+	return ""
+}
+
+// isPodReady checks if the given pod has the condition "Ready" set to "True".
+func isPodReady(pod *kubecorev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == kubecorev1.PodReady {
+			return condition.Status == kubecorev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// isNodeReady checks if the given node has the condition "Ready" set to "True".
+func isNodeReady(node *kubecorev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == kubecorev1.NodeReady {
+			return condition.Status == kubecorev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// sink publishes the scans of every round to a destination. Every sink
+// decides on its own how scans are represented. Sinks are called one after
+// another and do not need to be safe for concurrent use.
+type sink interface {
+	// publish publishes the given scans, one scan per check.
+	publish(ctx context.Context, checks []check, scans []scan) error
+
+	// publishShutdown publishes the given value as the status of all checks.
+	// It is called once the program is stopped by a signal.
+	publishShutdown(ctx context.Context, checks []check, value float64) error
+}
+
+// outputSink is a sink together with the failure policy of its output.
+type outputSink struct {
+	// Name of the output used in logs and errors. For example "CloudWatch".
+	name string
+
+	sink sink
+
+	// Fatal flag. Failures of fatal sinks count towards giving up, failures
+	// of best-effort sinks are only logged.
+	fatal bool
+}
+
+// newSinks creates one sink per configured output. Clients are verified
+// where possible. The returned function releases resources held by the sinks
+// and must be called once the sinks are not used anymore.
+func newSinks(
+	ctx context.Context,
+	log *slog.Logger,
+	config config,
+	prom *exporter,
+) ([]outputSink, func(), error) {
+	sinks := []outputSink{}
+	closers := []func(){}
+
+	closeSinks := func() {
+		for _, closeSink := range closers {
+			closeSink()
+		}
+	}
+
+	for i, output := range config.Outputs {
+		sink, closeSink, err := newSink(ctx, log, config, prom, output)
+		if err != nil {
+			closeSinks()
+
+			return nil, nil, fmt.Errorf("outputs[%v]: %v", i, err)
+		}
+
+		closers = append(closers, closeSink)
+
+		sinks = append(sinks, outputSink{
+			name:  output.Type,
+			sink:  sink,
+			fatal: output.FailurePolicy == failurePolicyFatal,
+		})
+	}
+
+	return sinks, closeSinks, nil
+}
+
+// newSink creates the sink of the given output. New destinations are added
+// here. The returned function releases resources held by the sink.
+//
+//nolint:ireturn // The sink depends on the output type.
+func newSink(
+	ctx context.Context,
+	log *slog.Logger,
+	config config,
+	prom *exporter,
+	output output,
+) (sink, func(), error) {
+	log = log.With(slog.String("output", output.Type))
+
+	switch output.Type {
+	case outputTypeEMF:
+		emf, err := newEMFClient(output.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create EMF client: %v", err)
+		}
+
+		closeSink := func() {
+			if err := emf.close(); err != nil {
+				log.Warn("Failed to close EMF client.", slog.Any("error", err))
+			}
+		}
+
+		emfSink := newMetricSink(
+			log, config, prom.instrument(output.Type, emf), nil,
+		)
+
+		return emfSink, closeSink, nil
+	case outputTypeOTLP:
+		exporter, err := newOTLPExporter(ctx, output)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create OTLP exporter: %v", err)
+		}
+
+		closeSink := func() { shutdownOTLPExporter(ctx, log, exporter) }
+
+		return newOTLPSink(log, config, exporter), closeSink, nil
+	default:
+		client, err := newCloudwatchClient(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create CloudWatch client: %v", err)
+		}
+
+		var datums *datumBuffer
+
+		if config.Buffer.Enabled {
+			datums, err = newDatumBuffer(
+				log, config.Buffer.Size, config.Buffer.Path,
+			)
+			if err != nil {
+				return nil, nil, fmt.Errorf("create datum buffer: %v", err)
+			}
+		}
+
+		cwSink := newMetricSink(
+			log, config, prom.instrument(output.Type, client), datums,
+		)
+
+		return cwSink, func() {}, nil
+	}
+}
+
+// metricSink publishes scans as CloudWatch datums with the given client. It
+// is used for CloudWatch and EMF outputs, which only differ in the client.
+type metricSink struct {
+	log *slog.Logger
+	dry bool
+
+	// CloudWatch client with required interface.
+	client cwPutMetricDataAPI
+
+	// Retries of failed calls.
+	retry retry
+
+	// Buffer for datums that could not be sent. Nil disables buffering.
+	buffer *datumBuffer
+
+	// Number of consecutive failed updates. Published together with the next
+	// update.
+	failures int
+}
+
+// newMetricSink creates a metric sink with the given client and buffer.
+func newMetricSink(
+	log *slog.Logger,
+	config config,
+	client cwPutMetricDataAPI,
+	buffer *datumBuffer,
+) *metricSink {
+	return &metricSink{
+		log:      log,
+		dry:      config.DryRun,
+		client:   client,
+		retry:    config.Retry,
+		buffer:   buffer,
+		failures: 0,
+	}
+}
+
+// publish updates the metrics of all checks with the results of the given
+// scans. The number of preceding failed updates is published as well.
+//
+// If buffering is enabled, failed updates are only logged, as their datums
+// are buffered and sent with the next successful update. Otherwise, an outage
+// longer than the configured consecutive failures would stop the program and
+// lose the buffer.
+func (s *metricSink) publish(
+	ctx context.Context,
+	checks []check,
+	scans []scan,
+) error {
+	updates := []metricUpdate{}
+	for i, check := range checks {
+		updates = append(updates, metricUpdate{
+			metric:    check.Metric,
+			value:     boolToFloat(scans[i].ready),
+			timestamp: scans[i].start,
+			failures:  s.failures,
+			results:   scans[i].results,
+		})
+	}
+
+	if err := s.update(ctx, updates); err != nil {
+		s.failures++
+
+		if s.buffer == nil {
+			return err
+		}
+
+		s.log.Warn(
+			"Failed to update metric. Datums are buffered and sent later.",
+			slog.Any("error", err),
+			slog.Int("consecutiveFailures", s.failures),
+			slog.Int("buffered", s.buffer.len()),
+		)
+
+		return nil
+	}
+
+	s.failures = 0
+
+	return nil
+}
+
+// publishShutdown updates the metrics of all checks with the given value.
+// Per-target datums are left out, as there are no results.
+func (s *metricSink) publishShutdown(
+	ctx context.Context,
+	checks []check,
+	value float64,
+) error {
+	now := time.Now()
+
+	updates := []metricUpdate{}
+	for _, check := range checks {
+		metric := check.Metric
+		metric.PerTarget, metric.ReplicaCounts = false, false
+
+		updates = append(updates, metricUpdate{
+			metric:    metric,
+			value:     value,
+			timestamp: now,
+			failures:  0,
+			results:   nil,
+		})
+	}
+
+	return s.update(ctx, updates)
+}
+
+// update calls updateMetric with the given updates.
+func (s *metricSink) update(ctx context.Context, updates []metricUpdate) error {
+	if err := updateMetric(&updateMetricOptions{
+		ctx:     ctx,
+		log:     s.log,
+		dry:     s.dry,
+		client:  s.client,
+		retry:   s.retry,
+		buffer:  s.buffer,
+		updates: updates,
+	}); err != nil {
+		return fmt.Errorf("update metric: %v", err)
+	}
+
+	return nil
+}
+
+// cwPutMetricDataAPI defines the interface for the PutMetricData function.
+// We use this interface to test the function using a mocked service.
+type cwPutMetricDataAPI interface {
+	PutMetricData(ctx context.Context,
+		params *cw.PutMetricDataInput,
+		optFns ...func(*cw.Options),
+	) (*cw.PutMetricDataOutput, error)
+}
+
+// emfClient implements cwPutMetricDataAPI by writing datums in the CloudWatch
+// embedded metric format (EMF) instead of calling PutMetricData. Every datum
+// is written as a single JSON line, so that a log shipper like Fluent Bit can
+// forward it to CloudWatch Logs, which extracts the metrics. It is not safe
+// for concurrent use.
+type emfClient struct {
+	writer io.Writer
+
+	// File the lines are appended to. Nil if writing to stdout.
+	file *os.File
+}
+
+// File mode of the EMF file if it is created.
+const emfFileMode = 0o600
+
+// newEMFClient creates an EMF client that appends lines to the file at the
+// given path. If the path is empty, lines are written to stdout.
+func newEMFClient(path string) (*emfClient, error) {
+	if path == "" {
+		return &emfClient{writer: os.Stdout, file: nil}, nil
+	}
+
+	//nolint:gosec // Path is populated from config.
+	file, err := os.OpenFile(
+		path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, emfFileMode,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("open EMF file: %v", err)
+	}
+
+	return &emfClient{writer: file, file: file}, nil
+}
+
+// close closes the file the lines are appended to, if any.
+func (c *emfClient) close() error {
+	if c.file == nil {
+		return nil
+	}
+
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("close EMF file: %v", err)
+	}
+
+	return nil
+}
+
+// PutMetricData writes every datum of the given input as a single EMF line.
+// Datums without timestamp are timestamped with the current time.
+func (c *emfClient) PutMetricData(
+	_ context.Context,
+	params *cw.PutMetricDataInput,
+	_ ...func(*cw.Options),
+) (*cw.PutMetricDataOutput, error) {
+	now := time.Now()
+
+	var lines []byte
+
+	for _, datum := range params.MetricData {
+		line, err := json.Marshal(newEMFLine(
+			aws.ToString(params.Namespace), datum, now,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("marshal EMF line: %v", err)
+		}
+
+		lines = append(append(lines, line...), '\n')
+	}
+
+	// All lines are written at once, so that a failed call can be retried
+	// without writing some of the lines twice.
+	if _, err := c.writer.Write(lines); err != nil {
+		return nil, fmt.Errorf("write EMF lines: %v", err)
+	}
+
+	return &cw.PutMetricDataOutput{}, nil
+}
+
+// emfMetadata is the metadata object of an EMF line, stored under "_aws".
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// emfDirective tells CloudWatch which members of an EMF line are metrics and
+// which are dimensions.
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+// emfMetric is the definition of a single metric in an EMF directive.
+type emfMetric struct {
+	Name              string `json:"Name"`
+	Unit              string `json:"Unit,omitempty"`
+	StorageResolution int32  `json:"StorageResolution,omitempty"`
+}
+
+// newEMFLine returns the EMF representation of the given datum. Dimension
+// values and the value of the metric are stored as members next to the
+// metadata. The given time is used if the datum has no timestamp.
+func newEMFLine(
+	namespace string,
+	datum cwtypes.MetricDatum,
+	now time.Time,
+) map[string]any {
+	name := aws.ToString(datum.MetricName)
+	timestamp := aws.ToTime(datum.Timestamp)
+
+	if timestamp.IsZero() {
+		timestamp = now
+	}
+
+	line := map[string]any{}
+	dimensionNames := []string{}
+
+	for _, dimension := range datum.Dimensions {
+		dimensionName := aws.ToString(dimension.Name)
+		dimensionNames = append(dimensionNames, dimensionName)
+		line[dimensionName] = aws.ToString(dimension.Value)
+	}
+
+	line[name] = aws.ToFloat64(datum.Value)
+	line["_aws"] = emfMetadata{
+		Timestamp: timestamp.UnixMilli(),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  namespace,
+			Dimensions: [][]string{dimensionNames},
+			Metrics: []emfMetric{{
+				Name:              name,
+				Unit:              string(datum.Unit),
+				StorageResolution: aws.ToInt32(datum.StorageResolution),
+			}},
+		}},
+	}
+
+	return line
+}
+
+// Name of the instrumentation scope of exported OTLP metrics.
+const otlpScopeName = "github.com/trallnag/kubestatus2cloudwatch"
+
+// Default URL path of the OTLP/HTTP metrics endpoint. Used if the configured
+// endpoint has no path.
+const otlpHTTPPath = "/v1/metrics"
+
+// Timeout for flushing and shutting down the OTLP exporter once the sink is
+// closed.
+const otlpShutdownTimeout = 5 * time.Second
+
+// otlpExportAPI defines the interface of OTLP metric exporters. It is
+// implemented by the gRPC and HTTP exporters of the OpenTelemetry SDK.
+type otlpExportAPI interface {
+	Export(ctx context.Context, metrics *metricdata.ResourceMetrics) error
+	Shutdown(ctx context.Context) error
+}
+
+// newOTLPExporter creates the OTLP exporter of the given output. Retries of
+// the exporter are disabled in favor of the configured ones. Without
+// endpoint, the exporter falls back to the standard environment variables
+// like "OTEL_EXPORTER_OTLP_ENDPOINT".
+//
+//nolint:ireturn // The exporter depends on the protocol.
+func newOTLPExporter(
+	ctx context.Context,
+	output output,
+) (otlpExportAPI, error) {
+	endpoint, err := url.Parse(output.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %v", err)
+	}
+
+	if output.Protocol == otlpProtocolHTTP {
+		options := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{
+				Enabled:         false,
+				InitialInterval: 0,
+				MaxInterval:     0,
+				MaxElapsedTime:  0,
+			}),
+		}
+
+		if output.Endpoint != "" {
+			if endpoint.Path == "" {
+				endpoint.Path = otlpHTTPPath
+			}
+
+			options = append(
+				options, otlpmetrichttp.WithEndpointURL(endpoint.String()),
+			)
+		}
+
+		exporter, err := otlpmetrichttp.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("create HTTP exporter: %v", err)
+		}
+
+		return exporter, nil
+	}
+
+	options := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+			Enabled:         false,
+			InitialInterval: 0,
+			MaxInterval:     0,
+			MaxElapsedTime:  0,
+		}),
+	}
+
+	if output.Endpoint != "" {
+		options = append(
+			options, otlpmetricgrpc.WithEndpointURL(endpoint.String()),
+		)
+	}
+
+	exporter, err := otlpmetricgrpc.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("create gRPC exporter: %v", err)
+	}
+
+	return exporter, nil
+}
+
+// shutdownOTLPExporter shuts down the given exporter. Failures are only
+// logged, as the exporter is not used anymore.
+func shutdownOTLPExporter(
+	ctx context.Context,
+	log *slog.Logger,
+	exporter otlpExportAPI,
+) {
+	ctx, cancel := context.WithTimeout(
+		context.WithoutCancel(ctx), otlpShutdownTimeout,
+	)
+	defer cancel()
+
+	if err := exporter.Shutdown(ctx); err != nil {
+		log.Warn("Failed to shut down OTLP exporter.", slog.Any("error", err))
+	}
+}
+
+// otlpSink publishes scans as OpenTelemetry gauges with the given exporter.
+// Every check is exported as its own resource with the dimensions of its
+// metric as resource attributes. Next to the aggregated value, there is one
+// data point per target with the target kind, namespace, and name as
+// attributes.
+type otlpSink struct {
+	log *slog.Logger
+	dry bool
+
+	// OTLP exporter with required interface.
+	exporter otlpExportAPI
+
+	// Retries of failed exports.
+	retry retry
+}
+
+// newOTLPSink creates an OTLP sink with the given exporter.
+func newOTLPSink(
+	log *slog.Logger,
+	config config,
+	exporter otlpExportAPI,
+) *otlpSink {
+	return &otlpSink{
+		log:      log,
+		dry:      config.DryRun,
+		exporter: exporter,
+		retry:    config.Retry,
+	}
+}
+
+// publish exports the gauges of all checks with the results of the given
+// scans.
+func (s *otlpSink) publish(
+	ctx context.Context,
+	checks []check,
+	scans []scan,
+) error {
+	metrics := []*metricdata.ResourceMetrics{}
+	for i, check := range checks {
+		points := []metricdata.DataPoint[float64]{
+			newOTLPDataPoint(
+				attribute.NewSet(), scans[i].start, boolToFloat(scans[i].ready),
+			),
+		}
+
+		for _, result := range scans[i].results {
+			points = append(points, newOTLPDataPoint(
+				newTargetAttributes(result), scans[i].start,
+				boolToFloat(result.ready),
+			))
+		}
+
+		metrics = append(metrics, newResourceMetrics(check.Metric, points))
+	}
+
+	return s.export(ctx, metrics)
+}
+
+// publishShutdown exports the gauges of all checks with the given value.
+// Per-target data points are left out, as there are no results.
+func (s *otlpSink) publishShutdown(
+	ctx context.Context,
+	checks []check,
+	value float64,
+) error {
+	now := time.Now()
+
+	metrics := []*metricdata.ResourceMetrics{}
+	for _, check := range checks {
+		metrics = append(metrics, newResourceMetrics(
+			check.Metric,
+			[]metricdata.DataPoint[float64]{
+				newOTLPDataPoint(attribute.NewSet(), now, value),
+			},
+		))
+	}
+
+	return s.export(ctx, metrics)
+}
+
+// export exports the given metrics one resource after another. Failed exports
+// are retried as configured. Nothing is exported in dry mode.
+func (s *otlpSink) export(
+	ctx context.Context,
+	metrics []*metricdata.ResourceMetrics,
+) error {
+	if s.dry {
+		return nil
+	}
+
+	for _, resourceMetrics := range metrics {
+		if err := callWithRetries(
+			ctx, s.log, s.retry, "export metrics",
+			func() error {
+				return s.exporter.Export(ctx, resourceMetrics)
+			},
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newResourceMetrics creates the OTLP metrics of the given metric config with
+// a single gauge that has the given data points. The dimensions become
+// resource attributes, next to the service name and the namespace of the
+// metric as service namespace.
+func newResourceMetrics(
+	metric metric,
+	points []metricdata.DataPoint[float64],
+) *metricdata.ResourceMetrics {
+	attributes := []attribute.KeyValue{
+		semconv.ServiceName(promNamespace),
+		semconv.ServiceNamespace(metric.Namespace),
+	}
+
+	for _, dimension := range metric.Dimensions {
+		attributes = append(
+			attributes, attribute.String(dimension.Name, dimension.Value),
+		)
+	}
+
+	return &metricdata.ResourceMetrics{
+		Resource: resource.NewWithAttributes(
+			semconv.SchemaURL, attributes...,
+		),
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope: instrumentation.Scope{
+				Name:       otlpScopeName,
+				Version:    version,
+				SchemaURL:  "",
+				Attributes: attribute.NewSet(),
+			},
+			Metrics: []metricdata.Metrics{{
+				Name:        metric.Name,
+				Description: "Status of the check. 1 if ready, 0 otherwise.",
+				Unit:        "1",
+				Data: metricdata.Gauge[float64]{
+					DataPoints: points,
+				},
+			}},
+		}},
+	}
+}
+
+// newOTLPDataPoint creates an OTLP data point.
+func newOTLPDataPoint(
+	attributes attribute.Set,
+	timestamp time.Time,
+	value float64,
+) metricdata.DataPoint[float64] {
+	return metricdata.DataPoint[float64]{
+		Attributes: attributes,
+		StartTime:  time.Time{},
+		Time:       timestamp,
+		Value:      value,
+		Exemplars:  nil,
+	}
+}
+
+// newTargetAttributes returns the kind, namespace, and name of the target the
+// given result belongs to as OTLP attributes. The attribute names are the
+// same as the ones of the per-target dimensions. The namespace is left out
+// for cluster-scoped targets like nodes.
+func newTargetAttributes(result result) attribute.Set {
+	attributes := []attribute.KeyValue{
+		attribute.String(dimensionTargetKind, result.kind),
+	}
+
+	if result.namespace != "" {
+		attributes = append(
+			attributes,
+			attribute.String(dimensionTargetNamespace, result.namespace),
+		)
+	}
+
+	attributes = append(
+		attributes, attribute.String(dimensionTargetName, result.name),
+	)
+
+	return attribute.NewSet(attributes...)
+}
+
+// updateMetricOptions holds the input for the updateMetric function.
+type updateMetricOptions struct {
+	ctx context.Context
+	log *slog.Logger
+	dry bool
+
+	// CloudWatch client with required interface.
+	client cwPutMetricDataAPI
+
+	// Retries of failed calls. At least one attempt is always made.
+	retry retry
+
+	// Buffer for datums that could not be sent. If set, datums of failed
+	// calls are buffered and buffered datums are flushed after successful
+	// calls. Nil disables buffering.
+	buffer *datumBuffer
+
+	// Updates of the metrics. Datums of all updates are sent together.
+	updates []metricUpdate
+}
+
+// metricUpdate holds the update of a single configured metric.
+type metricUpdate struct {
+	// Metric to update. Its flags decide which datums are sent.
+	metric metric
+
+	// Value of the aggregated datum. Usually 1 if ready and 0 if not.
+	value float64
+
+	// Timestamp of all datums. Usually the start time of the scan. If zero,
+	// CloudWatch uses the time of receipt.
+	timestamp time.Time
+
+	// Number of consecutive failed updates before this one. If greater than
+	// zero, it is sent as an additional datum.
+	failures int
+
+	// Results of the scan. Only used if perTarget or replicaCounts is enabled.
+	results []result
+}
+
+// updateMetric updates CloudWatch metrics using PutMetricData. Datums of all
+// updates are grouped by namespace and sent in batches, as few calls as
+// possible.
+//
+// If buffering is enabled, datums of a failed call are buffered with their
+// original timestamp and sent again after the next successful call. Once a
+// call failed, the remaining datums are buffered right away.
+func updateMetric(o *updateMetricOptions) error {
+	now := time.Now()
+
+	// Namespaces in order of their first appearance.
+	namespaces := []string{}
+	data := map[string][]cwtypes.MetricDatum{}
+
+	for _, update := range o.updates {
+		namespace := update.metric.Namespace
+		if _, ok := data[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+
+		data[namespace] = append(data[namespace], newMetricData(update)...)
+	}
+
+	if o.dry {
+		return nil
+	}
+
+	var err error
+
+	for _, namespace := range namespaces {
+		for batch := range slices.Chunk(data[namespace], maxDatumsPerCall) {
+			if err == nil {
+				err = putMetricData(o, &cw.PutMetricDataInput{
+					Namespace:  aws.String(namespace),
+					MetricData: batch,
+				})
+				if err == nil {
+					continue
+				}
+			}
+
+			if o.buffer != nil {
+				for i := range batch {
+					if batch[i].Timestamp == nil {
+						batch[i].Timestamp = aws.Time(now)
+					}
+				}
+
+				o.buffer.add(namespace, batch)
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if o.buffer != nil && o.buffer.len() > 0 {
+		o.log.Info(
+			"Flushing buffered datums.",
+			slog.Int("count", o.buffer.len()),
+		)
+
+		if err := o.buffer.flush(o.ctx, o.client); err != nil {
+			o.log.Warn(
+				"Failed to flush buffered datums. Trying again later.",
+				slog.Any("error", err),
+				slog.Int("remaining", o.buffer.len()),
+			)
+		}
+	}
+
+	return nil
+}
+
+// newMetricData returns the datums of the given update. The aggregated value
+// is always included. If requested, per-target datums are included as well,
+// with the target kind, namespace, and name added as dimensions.
+func newMetricData(update metricUpdate) []cwtypes.MetricDatum {
+	metricDimensions := []cwtypes.Dimension{}
+	for _, configDimension := range update.metric.Dimensions {
+		metricDimensions = append(metricDimensions, cwtypes.Dimension{
+			Name:  aws.String(configDimension.Name),
+			Value: aws.String(configDimension.Value),
+		})
+	}
+
+	metricData := []cwtypes.MetricDatum{
+		newMetricDatum(
+			update.metric.Name, metricDimensions, update.value,
+			cwtypes.StandardUnitNone,
+		),
+	}
+
+	if update.failures > 0 {
+		metricData = append(metricData, newMetricDatum(
+			metricNameFailedUpdates, metricDimensions,
+			float64(update.failures), cwtypes.StandardUnitCount,
+		))
+	}
+
+	for _, result := range update.results {
+		targetDimensions := newTargetDimensions(metricDimensions, result)
+
+		if update.metric.PerTarget {
+			metricData = append(metricData, newMetricDatum(
+				update.metric.Name, targetDimensions,
+				boolToFloat(result.ready), cwtypes.StandardUnitNone,
+			))
+		}
+
+		// Counts are only meaningful if the target could be queried.
+		if update.metric.ReplicaCounts && result.success {
+			ready := float64(result.replicas.ready)
+			desired := float64(result.replicas.desired)
+
+			readyRatio := 1.0
+			if desired > 0 {
+				readyRatio = ready / desired
+			}
+
+			metricData = append(
+				metricData,
+				newMetricDatum(
+					metricNameReadyReplicas, targetDimensions,
+					ready, cwtypes.StandardUnitCount,
+				),
+				newMetricDatum(
+					metricNameDesiredReplicas, targetDimensions,
+					desired, cwtypes.StandardUnitCount,
+				),
+				newMetricDatum(
+					metricNameReadyRatio, targetDimensions,
+					readyRatio, cwtypes.StandardUnitNone,
+				),
+			)
+		}
+	}
+
+	for i := range metricData {
+		if !update.timestamp.IsZero() {
+			metricData[i].Timestamp = aws.Time(update.timestamp)
+		}
+
+		if update.metric.StorageResolution > 0 {
+			metricData[i].StorageResolution = aws.Int32(
+				update.metric.StorageResolution,
+			)
+		}
+	}
+
+	return metricData
+}
+
+// putMetricData calls PutMetricData with the given input. Failed calls are
+// retried with backoff as configured.
+func putMetricData(
+	o *updateMetricOptions,
+	input *cw.PutMetricDataInput,
+) error {
+	return callWithRetries(
+		o.ctx, o.log, o.retry, "update metric",
+		func() error {
+			_, err := o.client.PutMetricData(o.ctx, input)
+
+			return err
+		},
+	)
+}
+
+// callWithRetries calls the given function until it succeeds, the context is
+// done, or the configured attempts are exhausted. Between attempts it waits
+// with backoff. The action is used in log messages and errors.
+func callWithRetries(
+	ctx context.Context,
+	log *slog.Logger,
+	retry retry,
+	action string,
+	call func() error,
+) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= retry.Attempts {
+			return fmt.Errorf(
+				"%v after %v attempts: %v", action, attempt, err,
+			)
+		}
+
+		backoff := newBackoff(retry, attempt)
+
+		log.Warn(
+			fmt.Sprintf("Failed to %v. Retrying.", action),
+			slog.Any("error", err),
+			slog.Int("attempt", attempt),
+			slog.String("backoff", backoff.String()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v: %v", action, err)
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Factor the backoff grows by with every failed attempt.
+const backoffMultiplier = 2
+
+// newBackoff returns the duration to wait after the given failed attempt. It
+// grows exponentially up to the maximum backoff. Jitter is applied so that
+// the result is between half and the full backoff.
+func newBackoff(retry retry, attempt int) time.Duration {
+	backoff := min(retry.InitialBackoff, retry.MaxBackoff)
+	for range attempt - 1 {
+		backoff = min(backoff*backoffMultiplier, retry.MaxBackoff)
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	half := backoff / backoffMultiplier
+
+	//nolint:gosec // Jitter does not need a secure random number generator.
+	return half + rand.N(backoff-half+1)
+}
+
+// Maximum number of datums CloudWatch accepts per PutMetricData call.
+const maxDatumsPerCall = 1000
+
+// Maximum age of datums CloudWatch accepts, with some margin. Older buffered
+// datums are dropped.
+const maxDatumAge = 14*24*time.Hour - time.Hour
+
+// bufferedDatum is a datum that could not be sent, together with the
+// namespace it belongs to.
+type bufferedDatum struct {
+	Namespace string              `json:"namespace"`
+	Datum     cwtypes.MetricDatum `json:"datum"`
+}
+
+// datumBuffer holds datums that could not be sent, oldest first. It is bounded
+// by size and drops the oldest datums if full. If path is set, the buffer is
+// persisted to the file after every change. It is not safe for concurrent
+// use.
+type datumBuffer struct {
+	log  *slog.Logger
+	size int
+	path string
+
+	datums []bufferedDatum
+}
+
+// newDatumBuffer creates a new datum buffer. If path is set and the file
+// exists, buffered datums are loaded from it.
+func newDatumBuffer(
+	log *slog.Logger,
+	size int,
+	path string,
+) (*datumBuffer, error) {
+	buffer := &datumBuffer{
+		log:    log,
+		size:   size,
+		path:   path,
+		datums: []bufferedDatum{},
+	}
+
+	if path == "" {
+		return buffer, nil
+	}
+
+	content, err := os.ReadFile(path) //nolint:gosec // Path is from config.
+	if errors.Is(err, os.ErrNotExist) {
+		return buffer, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read buffer file: %v", err)
+	}
+
+	if err := json.Unmarshal(content, &buffer.datums); err != nil {
+		return nil, fmt.Errorf("unmarshal buffer file: %v", err)
+	}
+
+	buffer.truncate()
+	buffer.prune(time.Now())
+
+	if len(buffer.datums) > 0 {
+		log.Info(
+			"Loaded buffered datums from file.",
+			slog.Int("count", len(buffer.datums)),
+			slog.String("path", path),
+		)
+	}
+
+	return buffer, nil
+}
+
+// len returns the number of buffered datums.
+func (b *datumBuffer) len() int {
+	return len(b.datums)
+}
+
+// add appends the given datums to the buffer.
+func (b *datumBuffer) add(namespace string, data []cwtypes.MetricDatum) {
+	for _, datum := range data {
+		b.datums = append(b.datums, bufferedDatum{
+			Namespace: namespace,
+			Datum:     datum,
+		})
+	}
+
+	b.truncate()
+	b.save()
+}
+
+// flush sends all buffered datums in batches. Datums of consecutive batches
+// share the same namespace. It stops at the first failed call and keeps the
+// datums that have not been sent yet.
+func (b *datumBuffer) flush(
+	ctx context.Context,
+	client cwPutMetricDataAPI,
+) error {
+	if b.prune(time.Now()) {
+		b.save()
+	}
+
+	for len(b.datums) > 0 {
+		namespace := b.datums[0].Namespace
+
+		batch := []cwtypes.MetricDatum{}
+		for _, buffered := range b.datums {
+			if buffered.Namespace != namespace ||
+				len(batch) == maxDatumsPerCall {
+				break
+			}
+
+			batch = append(batch, buffered.Datum)
+		}
+
+		_, err := client.PutMetricData(ctx, &cw.PutMetricDataInput{
+			Namespace:  aws.String(namespace),
+			MetricData: batch,
+		})
+		if err != nil {
+			return fmt.Errorf("put buffered datums: %v", err)
+		}
+
+		b.datums = b.datums[len(batch):]
+		b.save()
+	}
+
+	return nil
+}
+
+// truncate drops the oldest datums if the buffer holds more than its size.
+func (b *datumBuffer) truncate() {
+	dropped := len(b.datums) - b.size
+	if dropped <= 0 {
+		return
+	}
+
+	b.log.Warn(
+		"Datum buffer is full. Dropping oldest datums.",
+		slog.Int("dropped", dropped),
+	)
+
+	b.datums = slices.Clone(b.datums[dropped:])
+}
+
+// prune drops datums that are too old to be accepted by CloudWatch. It
+// reports whether any datums were dropped.
+func (b *datumBuffer) prune(now time.Time) bool {
+	count := len(b.datums)
+
+	b.datums = slices.DeleteFunc(b.datums, func(buffered bufferedDatum) bool {
+		timestamp := buffered.Datum.Timestamp
+
+		return timestamp != nil && now.Sub(*timestamp) > maxDatumAge
+	})
+
+	if dropped := count - len(b.datums); dropped > 0 {
+		b.log.Warn(
+			"Dropping buffered datums that are too old.",
+			slog.Int("dropped", dropped),
+		)
+
+		return true
+	}
+
+	return false
+}
+
+// save persists the buffer to the file if a path is set. The file is written
+// next to the target and then renamed, so that it is never left half-written.
+// Errors are logged and then swallowed.
+func (b *datumBuffer) save() {
+	if b.path == "" {
+		return
+	}
+
+	content, err := json.Marshal(b.datums)
+	if err == nil {
+		temporaryPath := b.path + ".tmp"
+
+		err = os.WriteFile(temporaryPath, content, 0o600)
+		if err == nil {
+			err = os.Rename(temporaryPath, b.path)
+		}
+	}
+
+	if err != nil {
+		b.log.Warn(
+			"Failed to persist datum buffer.",
+			slog.Any("error", err),
+			slog.String("path", b.path),
+		)
+	}
+}
+
+// newTargetDimensions extends the given dimensions with the kind, namespace,
+// and name of the target the given result belongs to. The namespace is left
+// out for cluster-scoped targets like nodes, as dimension values must not be
+// empty.
+func newTargetDimensions(
+	dimensions []cwtypes.Dimension,
+	result result,
+) []cwtypes.Dimension {
+	targetDimensions := append(
+		slices.Clone(dimensions),
+		cwtypes.Dimension{
+			Name:  aws.String(dimensionTargetKind),
+			Value: aws.String(result.kind),
+		},
+	)
+
+	if result.namespace != "" {
+		targetDimensions = append(targetDimensions, cwtypes.Dimension{
+			Name:  aws.String(dimensionTargetNamespace),
+			Value: aws.String(result.namespace),
+		})
+	}
+
+	return append(targetDimensions, cwtypes.Dimension{
+		Name:  aws.String(dimensionTargetName),
+		Value: aws.String(result.name),
+	})
+}
+
+// newMetricDatum creates a CloudWatch metric datum.
+func newMetricDatum(
+	name string,
+	dimensions []cwtypes.Dimension,
+	value float64,
+	unit cwtypes.StandardUnit,
+) cwtypes.MetricDatum {
+	return cwtypes.MetricDatum{
+		MetricName: aws.String(name),
+		Unit:       unit,
+		Value:      aws.Float64(value),
+		Dimensions: dimensions,
+	}
+}
+
+// boolToFloat converts the given value to 1 if true and 0 otherwise.
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1.0
+	}
+
+	return 0.0
+}
+
+// Namespace of all Prometheus metrics exposed by the HTTP server.
+const promNamespace = "kubestatus2cloudwatch"
+
+// Outcomes of PutMetricData calls used as label values.
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+// exporter holds the Prometheus metrics exposed by the HTTP server. They are
+// taken from the scans of executeRounds and the PutMetricData calls. All
+// methods are safe to call on a nil exporter and do nothing in that case.
+type exporter struct {
+	registry *prometheus.Registry
+
+	// Aggregate status per check and status per target.
+	checkReady  *prometheus.GaugeVec
+	targetReady *prometheus.GaugeVec
+
+	// Replica counts per target. Only set if the target could be queried.
+	readyReplicas   *prometheus.GaugeVec
+	desiredReplicas *prometheus.GaugeVec
+
+	// Duration of scans per check.
+	scanDuration *prometheus.HistogramVec
+
+	// Number of executed tick rounds.
+	ticks prometheus.Counter
+
+	// Number of PutMetricData calls by outcome.
+	putMetricData *prometheus.CounterVec
+
+	// Target series per check set by the last scans, so that only stale series
+	// are deleted. Only accessed by the goroutine that observes scans.
+	readySeries map[string]map[targetSeries]struct{}
+	countSeries map[string]map[targetSeries]struct{}
+}
+
+// targetSeries identifies the series of a target within a check.
+type targetSeries struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// labels returns the labels of the target series within the given check.
+func (s targetSeries) labels(check string) prometheus.Labels {
+	return prometheus.Labels{
+		"check":     check,
+		"kind":      s.kind,
+		"namespace": s.namespace,
+		"name":      s.name,
+	}
+}
+
+// newExporter creates an exporter with all metrics registered in a new
+// registry. Go runtime and process metrics are registered as well.
+func newExporter() *exporter {
+	targetLabels := []string{"check", "kind", "namespace", "name"}
+
+	e := &exporter{
+		registry: prometheus.NewRegistry(),
+		checkReady: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "check_ready",
+			Help:      "Aggregate status of the check, 1 if ready.",
+		}, []string{"check"}),
+		targetReady: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "target_ready",
+			Help:      "Status of the target, 1 if ready.",
+		}, targetLabels),
+		readyReplicas: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "target_ready_replicas",
+			Help:      "Number of ready replicas of the target.",
+		}, targetLabels),
+		desiredReplicas: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "target_desired_replicas",
+			Help:      "Number of desired replicas of the target.",
+		}, targetLabels),
+		scanDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "scan_duration_seconds",
+			Help:      "Duration of scans of all targets of the check.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"check"}),
+		ticks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "ticks_total",
+			Help:      "Number of executed tick rounds.",
+		}),
+		putMetricData: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "put_metric_data_total",
+			Help:      "Number of PutMetricData calls by output and outcome.",
+		}, []string{"output", "outcome"}),
+		readySeries: map[string]map[targetSeries]struct{}{},
+		countSeries: map[string]map[targetSeries]struct{}{},
+	}
+
+	e.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{
+			PidFn:        nil,
+			Namespace:    "",
+			ReportErrors: false,
+		}),
+		e.checkReady,
+		e.targetReady,
+		e.readyReplicas,
+		e.desiredReplicas,
+		e.scanDuration,
+		e.ticks,
+		e.putMetricData,
+	)
+
+	return e
+}
+
+// observeScans updates the metrics with the given scans, one scan per check.
+// Series of targets that are not part of the scan anymore, for example
+// because a label selector does not match an object anymore, are removed.
+// Current series are updated in place, so that they never go missing.
+func (e *exporter) observeScans(checks []check, scans []scan) {
+	if e == nil {
+		return
+	}
+
+	for i, check := range checks {
+		scan := scans[i]
+		checkLabels := prometheus.Labels{"check": check.Name}
+
+		e.checkReady.With(checkLabels).Set(boolToFloat(scan.ready))
+		e.scanDuration.With(checkLabels).Observe(scan.duration.Seconds())
+
+		ready := map[targetSeries]struct{}{}
+		counted := map[targetSeries]struct{}{}
+
+		for _, result := range scan.results {
+			series := targetSeries{
+				kind:      result.kind,
+				namespace: result.namespace,
+				name:      result.name,
+			}
+			targetLabels := series.labels(check.Name)
+
+			ready[series] = struct{}{}
+			e.targetReady.With(targetLabels).Set(boolToFloat(result.ready))
+
+			// Counts are only meaningful if the target could be queried.
+			if result.success {
+				counted[series] = struct{}{}
+				e.readyReplicas.With(targetLabels).Set(
+					float64(result.replicas.ready),
+				)
+				e.desiredReplicas.With(targetLabels).Set(
+					float64(result.replicas.desired),
+				)
+			}
+		}
+
+		for series := range e.readySeries[check.Name] {
+			if _, ok := ready[series]; !ok {
+				e.targetReady.Delete(series.labels(check.Name))
+			}
+		}
+
+		for series := range e.countSeries[check.Name] {
+			if _, ok := counted[series]; !ok {
+				e.readyReplicas.Delete(series.labels(check.Name))
+				e.desiredReplicas.Delete(series.labels(check.Name))
+			}
+		}
+
+		e.readySeries[check.Name] = ready
+		e.countSeries[check.Name] = counted
+	}
+}
+
+// observeTick counts an executed tick round.
+func (e *exporter) observeTick() {
+	if e == nil {
+		return
+	}
+
+	e.ticks.Inc()
+}
+
+// instrument wraps the given client of the given output, so that its
+// PutMetricData calls are counted by output and outcome. The client is
+// returned as is for a nil exporter.
+//
+//nolint:ireturn // The client is only wrapped if metrics are exposed.
+func (e *exporter) instrument(
+	output string,
+	client cwPutMetricDataAPI,
+) cwPutMetricDataAPI {
+	if e == nil {
+		return client
+	}
+
+	// Expose both outcomes right away, so that rates work from the start.
+	success := e.putMetricData.WithLabelValues(output, outcomeSuccess)
+	failure := e.putMetricData.WithLabelValues(output, outcomeFailure)
+
+	return &instrumentedClient{
+		client:  client,
+		success: success,
+		failure: failure,
+	}
+}
+
+// instrumentedClient counts the PutMetricData calls of the wrapped client by
+// outcome.
+type instrumentedClient struct {
+	client cwPutMetricDataAPI
+
+	// Counters of successful and failed calls.
+	success prometheus.Counter
+	failure prometheus.Counter
+}
+
+// PutMetricData calls the wrapped client and counts the outcome.
+func (c *instrumentedClient) PutMetricData(
+	ctx context.Context,
+	params *cw.PutMetricDataInput,
+	optFns ...func(*cw.Options),
+) (*cw.PutMetricDataOutput, error) {
+	output, err := c.client.PutMetricData(ctx, params, optFns...)
+	if err != nil {
+		c.failure.Inc()
+
+		return output, err
+	}
+
+	c.success.Inc()
+
+	return output, nil
+}
+
+// Maximum duration to read request headers. Protects against slow clients.
+const serverReadHeaderTimeout = 10 * time.Second
+
+// Maximum duration to wait for in-flight requests during shutdown.
+const serverShutdownTimeout = 5 * time.Second
+
+// newServer creates the HTTP server that exposes the metrics of the given
+// exporter at "/metrics" and the given probes at "/healthz" and "/readyz".
+func newServer(address string, prom *exporter, health *probes) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(
+		prom.registry,
+		promhttp.HandlerOpts{EnableOpenMetrics: true},
+	))
+	mux.Handle("GET /healthz", newProbeHandler(health.live))
+	mux.Handle("GET /readyz", newProbeHandler(health.ready))
+
+	return &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+	}
+}
+
+// startServer listens on the address of the given server and serves requests
+// in the background. Listening happens right away, so that errors like an
+// address already in use are returned. The returned function shuts the
+// server down gracefully.
+func startServer(
+	ctx context.Context,
+	log *slog.Logger,
+	server *http.Server,
+) (func(), error) {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", server.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %v", err)
+	}
+
+	log.Info(
+		"Serving metrics.",
+		slog.String("address", listener.Addr().String()),
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Failure while serving.", slog.Any("error", err))
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(
+			context.Background(), serverShutdownTimeout,
+		)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
+			log.Warn("Failed to shut down server.", slog.Any("error", err))
+		}
+
+		<-done
+	}, nil
+}
+
+// probes tracks the state reported by the liveness and readiness probes. All
+// methods are safe for concurrent use and do nothing on nil probes.
+type probes struct {
+	mu sync.Mutex
+
+	// Interval between rounds.
+	interval time.Duration
+
+	// Maximum duration a due round may be overdue before the liveness probe
+	// fails.
+	maxDelay time.Duration
+
+	// Time the next round is due. Zero until rounds are started, so that
+	// verifying clients and syncing the cache does not count.
+	nextRound time.Time
+
+	// Shows if the Kubernetes and CloudWatch clients have been verified.
+	verified bool
+
+	// Shows if at least one round published the metrics successfully.
+	succeeded bool
+}
+
+// newProbes creates probes with the given interval between rounds and the
+// given maximum delay of a due round.
+func newProbes(interval, maxDelay time.Duration) *probes {
+	return &probes{
+		mu:        sync.Mutex{},
+		interval:  interval,
+		maxDelay:  maxDelay,
+		nextRound: time.Time{},
+		verified:  false,
+		succeeded: false,
+	}
+}
+
+// markStarted records that rounds have been started. The first round is due
+// one interval later.
+func (p *probes) markStarted() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextRound = time.Now().Add(p.interval)
+}
+
+// markVerified records that the clients have been verified.
+func (p *probes) markVerified() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.verified = true
+}
+
+// markRound records a completed round. Rounds that failed to publish the
+// metrics still count as completed, as the process is not stuck.
+func (p *probes) markRound(success bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextRound = time.Now().Add(p.interval)
+	p.succeeded = p.succeeded || success
+}
+
+// live returns an error if the next round is overdue by more than the
+// maximum delay. Until rounds are started, it never returns an error.
+func (p *probes) live() error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nextRound.IsZero() {
+		return nil
+	}
+
+	if delay := time.Since(p.nextRound); delay > p.maxDelay {
+		return fmt.Errorf(
+			"round overdue by %v, allowed are %v",
+			delay.Truncate(time.Millisecond), p.maxDelay,
+		)
+	}
+
+	return nil
+}
+
+// ready returns an error until the clients have been verified and the first
+// round published the metrics successfully.
+func (p *probes) ready() error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.verified {
+		return fmt.Errorf("clients not verified yet")
+	}
+
+	if !p.succeeded {
+		return fmt.Errorf("no successful round yet")
+	}
+
+	return nil
+}
+
+// newProbeHandler returns a handler that responds with status 200 if the
+// given check passes and with status 503 and the error otherwise.
+func newProbeHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		_, _ = fmt.Fprintln(w, "ok")
+	})
 }
@@ -3,18 +3,45 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	aws "github.com/aws/aws-sdk-go-v2/aws"
 	cw "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	cmp "github.com/google/go-cmp/cmp"
 	godotenv "github.com/joho/godotenv"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	collectormetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	grpc "google.golang.org/grpc"
+	proto "google.golang.org/protobuf/proto"
 	kubeappsv1 "k8s.io/api/apps/v1"
+	kubebatchv1 "k8s.io/api/batch/v1"
+	kubecorev1 "k8s.io/api/core/v1"
 	kubemetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	kubedynamicfake "k8s.io/client-go/dynamic/fake"
+	kube "k8s.io/client-go/kubernetes"
 	kubefake "k8s.io/client-go/kubernetes/fake"
+	kubeappsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+	kubetesting "k8s.io/client-go/testing"
 )
 
 var dotEnv map[string]string
@@ -165,56 +192,133 @@ func TestRunMain_Config(t *testing.T) {
 // TestIsFittingMode tests the isFittingMode function.
 func TestIsFittingMode(t *testing.T) {
 	for _, tc := range []struct {
-		name    string // Name of test case.
-		mode    string // Fitting mode.
-		got     int    // Present number.
-		want    int    // Expected number.
-		fitting bool   // Is it fitting the mode?
+		name      string // Name of test case.
+		mode      string // Fitting mode.
+		threshold int    // Threshold for percentage mode.
+		minReady  int    // Minimum for min ready mode.
+		ready     int    // Ready replicas.
+		desired   int    // Desired replicas.
+		fitting   bool   // Is it fitting the mode?
 	}{{
 		name:    "UnknownMode",
 		mode:    "DoesNotExist",
-		got:     1,
-		want:    1,
+		ready:   1,
+		desired: 1,
 		fitting: false,
 	}, {
 		name:    "AotFitting",
 		mode:    modeAllOfThem,
-		got:     3,
-		want:    3,
+		ready:   3,
+		desired: 3,
+		fitting: true,
+	}, {
+		name:    "AotFittingSurplus",
+		mode:    modeAllOfThem,
+		ready:   4,
+		desired: 3,
 		fitting: true,
 	}, {
 		name:    "AotNotFitting",
 		mode:    modeAllOfThem,
-		got:     3,
-		want:    5,
+		ready:   3,
+		desired: 5,
 		fitting: false,
 	}, {
 		name:    "AotFittingZero",
 		mode:    modeAllOfThem,
-		got:     0,
-		want:    0,
+		ready:   0,
+		desired: 0,
 		fitting: true,
 	}, {
 		name:    "AloFittingZero",
 		mode:    modeAtLeastOne,
-		got:     3,
-		want:    0,
+		ready:   3,
+		desired: 0,
 		fitting: true,
 	}, {
 		name:    "AloFitting",
 		mode:    modeAtLeastOne,
-		got:     1,
-		want:    8,
+		ready:   1,
+		desired: 8,
 		fitting: true,
 	}, {
 		name:    "AloNotFitting",
 		mode:    modeAtLeastOne,
-		got:     0,
-		want:    3,
+		ready:   0,
+		desired: 3,
 		fitting: false,
+	}, {
+		name:      "PctFitting",
+		mode:      modePercentage,
+		threshold: 80,
+		ready:     4,
+		desired:   5,
+		fitting:   true,
+	}, {
+		name:      "PctNotFitting",
+		mode:      modePercentage,
+		threshold: 80,
+		ready:     7,
+		desired:   9,
+		fitting:   false,
+	}, {
+		name:      "PctFittingZero",
+		mode:      modePercentage,
+		threshold: 100,
+		ready:     0,
+		desired:   0,
+		fitting:   true,
+	}, {
+		name:     "MinFitting",
+		mode:     modeMinReady,
+		minReady: 3,
+		ready:    3,
+		desired:  10,
+		fitting:  true,
+	}, {
+		name:     "MinNotFitting",
+		mode:     modeMinReady,
+		minReady: 3,
+		ready:    2,
+		desired:  2,
+		fitting:  false,
+	}, {
+		name:     "AotFloorFitting",
+		mode:     modeAllOfThem,
+		minReady: 3,
+		ready:    3,
+		desired:  3,
+		fitting:  true,
+	}, {
+		name:     "AotFloorNotFitting",
+		mode:     modeAllOfThem,
+		minReady: 3,
+		ready:    2,
+		desired:  2,
+		fitting:  false,
+	}, {
+		name:     "AloFloorNotFittingZero",
+		mode:     modeAtLeastOne,
+		minReady: 1,
+		ready:    0,
+		desired:  0,
+		fitting:  false,
+	}, {
+		name:      "PctFloorNotFitting",
+		mode:      modePercentage,
+		threshold: 50,
+		minReady:  2,
+		ready:     1,
+		desired:   1,
+		fitting:   false,
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
-			if tc.fitting != isFittingMode(tc.mode, tc.got, tc.want) {
+			if tc.fitting != isFittingMode(result{
+				mode:      tc.mode,
+				threshold: tc.threshold,
+				minReady:  tc.minReady,
+				replicas:  replicas{ready: tc.ready, desired: tc.desired},
+			}) {
 				t.Errorf(
 					"Unexpected return: got %v, want %v",
 					tc.fitting,
@@ -238,7 +342,12 @@ func TestPerformScan(t *testing.T) {
 	}{{
 		name: "DaemonSetQueryFailure",
 		targets: []target{
-			{kindDaemonSet, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindDaemonSet,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{false},
 		expResultReady:   []bool{false},
@@ -247,7 +356,12 @@ func TestPerformScan(t *testing.T) {
 	}, {
 		name: "DeploymentQueryFailure",
 		targets: []target{
-			{kindDeployment, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindDeployment,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{false},
 		expResultReady:   []bool{false},
@@ -256,7 +370,12 @@ func TestPerformScan(t *testing.T) {
 	}, {
 		name: "StatefulsetQueryFailure",
 		targets: []target{
-			{kindStatefulSet, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindStatefulSet,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{false},
 		expResultReady:   []bool{false},
@@ -265,7 +384,12 @@ func TestPerformScan(t *testing.T) {
 	}, {
 		name: "UnsupportedKind",
 		targets: []target{
-			{"NotSupported", "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      "NotSupported",
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{false},
 		expResultReady:   []bool{false},
@@ -286,7 +410,12 @@ func TestPerformScan(t *testing.T) {
 			},
 		},
 		targets: []target{
-			{kindDaemonSet, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindDaemonSet,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{true},
 		expResultReady:   []bool{true},
@@ -300,6 +429,9 @@ func TestPerformScan(t *testing.T) {
 					Namespace: "Foo",
 					Name:      "Baz",
 				},
+				Spec: kubeappsv1.DeploymentSpec{
+					Replicas: getInt32Ptr(t, 1),
+				},
 				Status: kubeappsv1.DeploymentStatus{
 					Replicas:      1,
 					ReadyReplicas: 1,
@@ -307,7 +439,12 @@ func TestPerformScan(t *testing.T) {
 			},
 		},
 		targets: []target{
-			{kindDeployment, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindDeployment,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{true},
 		expResultReady:   []bool{true},
@@ -321,6 +458,9 @@ func TestPerformScan(t *testing.T) {
 					Namespace: "Foo",
 					Name:      "Baz",
 				},
+				Spec: kubeappsv1.StatefulSetSpec{
+					Replicas: getInt32Ptr(t, 1),
+				},
 				Status: kubeappsv1.StatefulSetStatus{
 					Replicas:      1,
 					ReadyReplicas: 1,
@@ -328,7 +468,12 @@ func TestPerformScan(t *testing.T) {
 			},
 		},
 		targets: []target{
-			{kindStatefulSet, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindStatefulSet,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{true},
 		expResultReady:   []bool{true},
@@ -342,6 +487,9 @@ func TestPerformScan(t *testing.T) {
 					Namespace: "Foo",
 					Name:      "Baz",
 				},
+				Spec: kubeappsv1.StatefulSetSpec{
+					Replicas: getInt32Ptr(t, 5),
+				},
 				Status: kubeappsv1.StatefulSetStatus{
 					Replicas:      5,
 					ReadyReplicas: 1,
@@ -349,7 +497,131 @@ func TestPerformScan(t *testing.T) {
 			},
 		},
 		targets: []target{
-			{kindStatefulSet, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindStatefulSet,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
+		},
+		expResultSuccess: []bool{true},
+		expResultReady:   []bool{false},
+		expScanSuccess:   true,
+		expScanReady:     false,
+	}, {
+		name: "DeploymentAtLeastOneNotReady",
+		objects: []kuberuntime.Object{
+			newDeployment(t, "Foo", "Baz", nil, 3, 0),
+		},
+		targets: []target{
+			{
+				Kind:      kindDeployment,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAtLeastOne,
+			},
+		},
+		expResultSuccess: []bool{true},
+		expResultReady:   []bool{false},
+		expScanSuccess:   true,
+		expScanReady:     false,
+	}, {
+		name: "DeploymentPercentageReady",
+		objects: []kuberuntime.Object{
+			newDeployment(t, "Foo", "Baz", nil, 10, 8),
+		},
+		targets: []target{
+			{
+				Kind:      kindDeployment,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modePercentage,
+				Threshold: 80,
+			},
+		},
+		expResultSuccess: []bool{true},
+		expResultReady:   []bool{true},
+		expScanSuccess:   true,
+		expScanReady:     true,
+	}, {
+		name: "DeploymentCheckConditionsNotReady",
+		objects: []kuberuntime.Object{
+			&kubeappsv1.Deployment{
+				ObjectMeta: kubemetav1.ObjectMeta{
+					Namespace:  "Foo",
+					Name:       "Baz",
+					Generation: 2,
+				},
+				Status: kubeappsv1.DeploymentStatus{
+					ObservedGeneration: 1,
+					Replicas:           1,
+					ReadyReplicas:      1,
+				},
+			},
+		},
+		targets: []target{
+			{
+				Kind:            kindDeployment,
+				Namespace:       "Foo",
+				Name:            "Baz",
+				Mode:            modeAllOfThem,
+				CheckConditions: true,
+			},
+		},
+		expResultSuccess: []bool{true},
+		expResultReady:   []bool{false},
+		expScanSuccess:   true,
+		expScanReady:     false,
+	}, {
+		name: "PodSuccessReady",
+		objects: []kuberuntime.Object{
+			newPod(t, "Foo", "a", map[string]string{"app": "x"}, true),
+			newPod(t, "Foo", "b", map[string]string{"app": "x"}, true),
+			newPod(t, "Foo", "c", map[string]string{"app": "y"}, false),
+			newPod(t, "Bar", "d", map[string]string{"app": "x"}, false),
+		},
+		targets: []target{
+			{
+				Kind:          kindPod,
+				Namespace:     "Foo",
+				LabelSelector: "app=x",
+				Mode:          modeAllOfThem,
+			},
+		},
+		expResultSuccess: []bool{true},
+		expResultReady:   []bool{true},
+		expScanSuccess:   true,
+		expScanReady:     true,
+	}, {
+		name: "PodSuccessNotReady",
+		objects: []kuberuntime.Object{
+			newPod(t, "Foo", "a", map[string]string{"app": "x"}, true),
+			newPod(t, "Foo", "b", map[string]string{"app": "x"}, false),
+		},
+		targets: []target{
+			{
+				Kind:          kindPod,
+				Namespace:     "Foo",
+				LabelSelector: "app=x",
+				Mode:          modeAllOfThem,
+			},
+		},
+		expResultSuccess: []bool{true},
+		expResultReady:   []bool{false},
+		expScanSuccess:   true,
+		expScanReady:     false,
+	}, {
+		name: "PodNoMatch",
+		objects: []kuberuntime.Object{
+			newPod(t, "Foo", "a", map[string]string{"app": "y"}, true),
+		},
+		targets: []target{
+			{
+				Kind:          kindPod,
+				Namespace:     "Foo",
+				LabelSelector: "app=x",
+				Mode:          modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{true},
 		expResultReady:   []bool{false},
@@ -363,6 +635,9 @@ func TestPerformScan(t *testing.T) {
 					Namespace: "Foo",
 					Name:      "Baz",
 				},
+				Spec: kubeappsv1.StatefulSetSpec{
+					Replicas: getInt32Ptr(t, 5),
+				},
 				Status: kubeappsv1.StatefulSetStatus{
 					Replicas:      5,
 					ReadyReplicas: 1,
@@ -373,6 +648,9 @@ func TestPerformScan(t *testing.T) {
 					Namespace: "Foo",
 					Name:      "Baz",
 				},
+				Spec: kubeappsv1.DeploymentSpec{
+					Replicas: getInt32Ptr(t, 1),
+				},
 				Status: kubeappsv1.DeploymentStatus{
 					Replicas:      1,
 					ReadyReplicas: 1,
@@ -380,8 +658,18 @@ func TestPerformScan(t *testing.T) {
 			},
 		},
 		targets: []target{
-			{kindStatefulSet, "Foo", "Baz", modeAllOfThem},
-			{kindDeployment, "Foo", "Baz", modeAllOfThem},
+			{
+				Kind:      kindStatefulSet,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
+			{
+				Kind:      kindDeployment,
+				Namespace: "Foo",
+				Name:      "Baz",
+				Mode:      modeAllOfThem,
+			},
 		},
 		expResultSuccess: []bool{true, true},
 		expResultReady:   []bool{false, true},
@@ -433,12 +721,17 @@ func TestPerformScan(t *testing.T) {
 					)
 				}
 
-				if scan.results[i].name != tc.targets[i].Name {
+				expName := tc.targets[i].Name
+				if expName == "" {
+					expName = tc.targets[i].LabelSelector
+				}
+
+				if scan.results[i].name != expName {
 					t.Errorf(
 						"Unexpected name for result %v: got %v, want %v",
 						i,
 						scan.results[i].name,
-						tc.targets[i].Name,
+						expName,
 					)
 				}
 
@@ -464,167 +757,4042 @@ func TestPerformScan(t *testing.T) {
 	}
 }
 
-// cwPutMetricDataImpl implements CWPutMetricDataAPI. Based on this example:
-// https://github.com/awsdocs/aws-doc-sdk-examples/tree/main/gov2/cloudwatch/CreateCustomMetric
-type cwPutMetricDataImpl struct {
-	returnError bool
-}
-
-// PutMetricData implements CWPutMetricDataAPI. Based on this example:
-// https://github.com/awsdocs/aws-doc-sdk-examples/tree/main/gov2/cloudwatch/CreateCustomMetric
-func (dt cwPutMetricDataImpl) PutMetricData(
-	_ context.Context,
-	_ *cw.PutMetricDataInput,
-	_ ...func(*cw.Options),
-) (*cw.PutMetricDataOutput, error) {
-	if dt.returnError {
-		return &cw.PutMetricDataOutput{}, fmt.Errorf("fake error")
-	}
+// TestPerformScanKindsModes tests the performScan function for every kind in
+// combination with every mode. In all cases 3 out of 4 replicas are ready.
+func TestPerformScanKindsModes(t *testing.T) {
+	meta := kubemetav1.ObjectMeta{Namespace: "Foo", Name: "Baz"}
 
-	return &cw.PutMetricDataOutput{}, nil
-}
+	kindCases := []struct {
+		kind        string               // Kind of target.
+		objects     []kuberuntime.Object // Kubernetes objects.
+		expReplicas replicas             // Expected replica counts.
+	}{{
+		kind: kindDaemonSet,
+		objects: []kuberuntime.Object{
+			&kubeappsv1.DaemonSet{
+				ObjectMeta: meta,
+				Status: kubeappsv1.DaemonSetStatus{
+					DesiredNumberScheduled: 4,
+					NumberReady:            3,
+					NumberAvailable:        2,
+					UpdatedNumberScheduled: 4,
+				},
+			},
+		},
+		expReplicas: replicas{desired: 4, ready: 3, available: 2, updated: 4},
+	}, {
+		kind: kindDeployment,
+		objects: []kuberuntime.Object{
+			&kubeappsv1.Deployment{
+				ObjectMeta: meta,
+				Spec: kubeappsv1.DeploymentSpec{
+					Replicas: getInt32Ptr(t, 4),
+				},
+				Status: kubeappsv1.DeploymentStatus{
+					Replicas:          5,
+					ReadyReplicas:     3,
+					AvailableReplicas: 2,
+					UpdatedReplicas:   1,
+				},
+			},
+		},
+		expReplicas: replicas{desired: 4, ready: 3, available: 2, updated: 1},
+	}, {
+		kind: kindStatefulSet,
+		objects: []kuberuntime.Object{
+			&kubeappsv1.StatefulSet{
+				ObjectMeta: meta,
+				Spec: kubeappsv1.StatefulSetSpec{
+					Replicas: getInt32Ptr(t, 4),
+				},
+				Status: kubeappsv1.StatefulSetStatus{
+					Replicas:          4,
+					ReadyReplicas:     3,
+					AvailableReplicas: 3,
+					UpdatedReplicas:   2,
+				},
+			},
+		},
+		expReplicas: replicas{desired: 4, ready: 3, available: 3, updated: 2},
+	}, {
+		kind: kindPod,
+		objects: []kuberuntime.Object{
+			newPod(t, "Foo", "a", map[string]string{"app": "Baz"}, true),
+			newPod(t, "Foo", "b", map[string]string{"app": "Baz"}, true),
+			newPod(t, "Foo", "c", map[string]string{"app": "Baz"}, true),
+			newPod(t, "Foo", "d", map[string]string{"app": "Baz"}, false),
+		},
+		expReplicas: replicas{desired: 4, ready: 3, available: 3, updated: 4},
+	}}
 
-// TestUpdateMetric tests the updateMetric function.
-func TestUpdateMetric(t *testing.T) {
-	for _, tc := range []struct {
-		name        string // Name of test case.
-		value       bool   // Value of metric. True is 1 and false is 0.
-		dryRun      bool   // Enable dry run mode.
-		returnError bool   // Should the mock return an error?
-		expSuccess  bool   // Is the call expected to succeed?
+	modeCases := []struct {
+		name      string // Name of mode case.
+		mode      string // Mode of target.
+		threshold int    // Threshold for percentage mode.
+		minReady  int    // Minimum for min ready mode.
+		expReady  bool   // Expected ready status.
 	}{{
-		name:        "SuccessOne",
-		value:       true,
-		returnError: false,
-		expSuccess:  true,
+		name:     "AllOfThem",
+		mode:     modeAllOfThem,
+		expReady: false,
 	}, {
-		name:        "SuccessZero",
-		value:       false,
-		returnError: false,
-		expSuccess:  true,
+		name:     "AtLeastOne",
+		mode:     modeAtLeastOne,
+		expReady: true,
 	}, {
-		name:        "Failure",
-		value:       false,
-		returnError: true,
-		expSuccess:  false,
+		name:      "PercentageFitting",
+		mode:      modePercentage,
+		threshold: 75,
+		expReady:  true,
 	}, {
-		name:        "DryFailure",
-		value:       true,
-		dryRun:      true,
-		returnError: true,
-		expSuccess:  true,
-	}} {
-		t.Run(tc.name, func(t *testing.T) {
-			err := updateMetric(&updateMetricOptions{
-				ctx:        t.Context(),
-				dry:        tc.dryRun,
-				client:     &cwPutMetricDataImpl{tc.returnError},
-				namespace:  "MyNamespace",
-				name:       "MyMetric",
-				dimensions: []dimension{{Name: "Cluster", Value: "MyCluster"}},
-				value:      tc.value,
-			})
+		name:      "PercentageNotFitting",
+		mode:      modePercentage,
+		threshold: 80,
+		expReady:  false,
+	}, {
+		name:     "MinReadyFitting",
+		mode:     modeMinReady,
+		minReady: 3,
+		expReady: true,
+	}, {
+		name:     "MinReadyNotFitting",
+		mode:     modeMinReady,
+		minReady: 4,
+		expReady: false,
+	}}
 
-			if tc.expSuccess && err != nil {
-				t.Errorf("Unexpected failure: %v", err)
-			}
+	for _, kc := range kindCases {
+		for _, mc := range modeCases {
+			t.Run(kc.kind+mc.name, func(t *testing.T) {
+				scanTarget := target{
+					Kind:      kc.kind,
+					Namespace: "Foo",
+					Name:      "Baz",
+					Mode:      mc.mode,
+					Threshold: mc.threshold,
+					MinReady:  mc.minReady,
+				}
+				if kc.kind == kindPod {
+					scanTarget.Name, scanTarget.LabelSelector = "", "app=Baz"
+				}
 
-			if !tc.expSuccess && err == nil {
-				t.Errorf("Unexpected success")
-			}
-		})
-	}
+				scan := performScan(&performScanOptions{
+					ctx:     t.Context(),
+					log:     newLogger(t),
+					client:  kubefake.NewSimpleClientset(kc.objects...),
+					targets: []target{scanTarget},
+				})
 
-	t.Run("SuccessNilDimensions", func(t *testing.T) {
-		err := updateMetric(&updateMetricOptions{
-			ctx:        t.Context(),
-			dry:        false,
-			client:     &cwPutMetricDataImpl{false},
-			namespace:  "MyNamespace",
-			name:       "MyMetric",
-			dimensions: nil,
-			value:      true,
-		})
-		if err != nil {
-			t.Errorf("Unexpected failure: %v", err)
+				if len(scan.results) != 1 {
+					t.Fatalf(
+						"Unexpected number of results: got %v, want %v",
+						len(scan.results),
+						1,
+					)
+				}
+
+				result := scan.results[0]
+
+				if !result.success {
+					t.Errorf("Unexpected failure: %v", result.reason)
+				}
+
+				if result.ready != mc.expReady {
+					t.Errorf(
+						"Unexpected ready status: got %v, want %v",
+						result.ready,
+						mc.expReady,
+					)
+				}
+
+				if result.replicas != kc.expReplicas {
+					t.Errorf(
+						"Unexpected replicas: got %+v, want %+v",
+						result.replicas,
+						kc.expReplicas,
+					)
+				}
+			})
 		}
-	})
+	}
 }
 
-// TestExecuteRounds tests the executeRounds function.
-func TestExecuteRounds(t *testing.T) {
+// TestCheckDeploymentConditions tests the checkDeploymentConditions function.
+func TestCheckDeploymentConditions(t *testing.T) {
+	available := kubeappsv1.DeploymentCondition{
+		Type:   kubeappsv1.DeploymentAvailable,
+		Status: kubecorev1.ConditionTrue,
+	}
+	unavailable := kubeappsv1.DeploymentCondition{
+		Type:   kubeappsv1.DeploymentAvailable,
+		Status: kubecorev1.ConditionFalse,
+	}
+	progressing := kubeappsv1.DeploymentCondition{
+		Type:   kubeappsv1.DeploymentProgressing,
+		Status: kubecorev1.ConditionTrue,
+		Reason: "NewReplicaSetAvailable",
+	}
+	stalled := kubeappsv1.DeploymentCondition{
+		Type:   kubeappsv1.DeploymentProgressing,
+		Status: kubecorev1.ConditionFalse,
+		Reason: "ProgressDeadlineExceeded",
+	}
+
 	for _, tc := range []struct {
-		name    string // Name of test case.
-		cwError bool   // Should the mock return an error?
+		name       string                           // Name of test case.
+		generation int64                            // Generation.
+		observed   int64                            // Observed generation.
+		conditions []kubeappsv1.DeploymentCondition // Conditions.
+		expReason  string                           // Expected reason.
 	}{{
-		name:    "CloudwatchSuccess",
-		cwError: false,
+		name:       "Healthy",
+		generation: 3,
+		observed:   3,
+		conditions: []kubeappsv1.DeploymentCondition{
+			available, progressing,
+		},
+		expReason: "",
 	}, {
-		name:    "CloudwatchFailure",
-		cwError: true,
+		name:       "StaleGeneration",
+		generation: 3,
+		observed:   2,
+		conditions: []kubeappsv1.DeploymentCondition{
+			available, progressing,
+		},
+		expReason: "observed generation 2 behind generation 3",
+	}, {
+		name:       "ProgressDeadlineExceeded",
+		generation: 3,
+		observed:   3,
+		conditions: []kubeappsv1.DeploymentCondition{
+			available, stalled,
+		},
+		expReason: "progress deadline exceeded",
+	}, {
+		name:       "NotAvailable",
+		generation: 3,
+		observed:   3,
+		conditions: []kubeappsv1.DeploymentCondition{
+			unavailable, progressing,
+		},
+		expReason: "not available",
+	}, {
+		name:       "NoConditions",
+		generation: 1,
+		observed:   1,
+		conditions: nil,
+		expReason:  "not available",
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
-			log := newLogger(t)
-
-			err := executeRounds(&executeRoundsOptions{
-				ctx:      t.Context(),
-				log:      log,
-				dry:      false,
-				kClient:  kubefake.NewSimpleClientset(),
-				cwClient: &cwPutMetricDataImpl{tc.cwError},
-				single:   true,
-				seconds:  1,
-				metric: metric{
-					Namespace:  "Namespace",
-					Name:       "Name",
-					Dimensions: []dimension{},
+			reason := checkDeploymentConditions(&kubeappsv1.Deployment{
+				ObjectMeta: kubemetav1.ObjectMeta{Generation: tc.generation},
+				Status: kubeappsv1.DeploymentStatus{
+					ObservedGeneration: tc.observed,
+					Conditions:         tc.conditions,
 				},
-				targets: []target{{
-					Kind:      kindDeployment,
-					Mode:      modeAllOfThem,
-					Namespace: "Namespace",
-					Name:      "Name",
-				}},
 			})
-
-			if tc.cwError && err == nil {
-				t.Errorf("Expected failure, got success")
-			}
-
-			if !tc.cwError && err != nil {
-				t.Errorf("Unexpected failure: %v", err)
+			if reason != tc.expReason {
+				t.Errorf(
+					"Unexpected reason: got %q, want %q",
+					reason,
+					tc.expReason,
+				)
 			}
 		})
 	}
+}
 
-	t.Run("ContextCancel", func(t *testing.T) {
-		log := newLogger(t)
-
-		ctx, cancel := context.WithCancel(t.Context())
-		cancel()
+// TestPerformScanSelectors tests the performScan function with targets that
+// select namespaces and objects with label selectors.
+func TestPerformScanSelectors(t *testing.T) {
+	objects := []kuberuntime.Object{
+		&kubecorev1.Namespace{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Name:   "Foo",
+				Labels: map[string]string{"team": "a"},
+			},
+		},
+		&kubecorev1.Namespace{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Name:   "Bar",
+				Labels: map[string]string{"team": "a"},
+			},
+		},
+		&kubecorev1.Namespace{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Name:   "Baz",
+				Labels: map[string]string{"team": "b"},
+			},
+		},
+		newDeployment(t, "Foo", "x", map[string]string{"app": "x"}, 2, 2),
+		newDeployment(t, "Foo", "y", map[string]string{"app": "x"}, 2, 1),
+		newDeployment(t, "Foo", "z", map[string]string{"app": "z"}, 2, 1),
+		newDeployment(t, "Bar", "x", map[string]string{"app": "x"}, 1, 1),
+		newDeployment(t, "Baz", "x", map[string]string{"app": "x"}, 1, 0),
+		newPod(t, "Foo", "a", map[string]string{"app": "x"}, true),
+		newPod(t, "Bar", "b", map[string]string{"app": "x"}, true),
+		newPod(t, "Baz", "c", map[string]string{"app": "x"}, false),
+	}
+
+	for _, tc := range []struct {
+		name       string   // Name of test case.
+		target     target   // Target to scan.
+		expResults []string // Expected results as namespace/name=ready.
+	}{{
+		name: "LabelSelector",
+		target: target{
+			Kind:          kindDeployment,
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+			Mode:          modeAllOfThem,
+		},
+		expResults: []string{"Foo/x=true", "Foo/y=false"},
+	}, {
+		name: "NamespaceSelector",
+		target: target{
+			Kind:              kindDeployment,
+			NamespaceSelector: "team=a",
+			Name:              "x",
+			Mode:              modeAllOfThem,
+		},
+		expResults: []string{"Bar/x=true", "Foo/x=true"},
+	}, {
+		name: "NamespaceSelectorLabelSelector",
+		target: target{
+			Kind:              kindDeployment,
+			NamespaceSelector: "team=a",
+			LabelSelector:     "app=x",
+			Mode:              modeAllOfThem,
+		},
+		expResults: []string{"Bar/x=true", "Foo/x=true", "Foo/y=false"},
+	}, {
+		name: "NamespaceSelectorPods",
+		target: target{
+			Kind:              kindPod,
+			NamespaceSelector: "team=a",
+			LabelSelector:     "app=x",
+			Mode:              modeAllOfThem,
+		},
+		expResults: []string{"team=a/app=x=true"},
+	}, {
+		name: "NoMatchDefault",
+		target: target{
+			Kind:          kindDeployment,
+			Namespace:     "Foo",
+			LabelSelector: "app=none",
+			Mode:          modeAllOfThem,
+		},
+		expResults: []string{"Foo/app=none=false"},
+	}, {
+		name: "NoMatchReady",
+		target: target{
+			Kind:              kindDeployment,
+			NamespaceSelector: "team=none",
+			LabelSelector:     "app=x",
+			Mode:              modeAllOfThem,
+			OnNoMatch:         noMatchReady,
+		},
+		expResults: []string{"team=none/app=x=true"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			scan := performScan(&performScanOptions{
+				ctx:     t.Context(),
+				log:     newLogger(t),
+				client:  kubefake.NewSimpleClientset(objects...),
+				targets: []target{tc.target},
+			})
+
+			gotResults := []string{}
+			for _, result := range scan.results {
+				gotResults = append(gotResults, fmt.Sprintf(
+					"%v/%v=%v", result.namespace, result.name, result.ready,
+				))
+			}
+
+			if diff := cmp.Diff(tc.expResults, gotResults); diff != "" {
+				t.Errorf("Results mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// TestPerformScanJobs tests the performScan function with targets of kind
+// Job and CronJob.
+func TestPerformScanJobs(t *testing.T) {
+	objects := []kuberuntime.Object{
+		&kubebatchv1.Job{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Namespace: "Foo",
+				Name:      "complete",
+			},
+			Spec: kubebatchv1.JobSpec{
+				Completions: getInt32Ptr(t, 2),
+			},
+			Status: kubebatchv1.JobStatus{
+				Succeeded: 2,
+			},
+		},
+		&kubebatchv1.Job{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Namespace: "Foo",
+				Name:      "running",
+			},
+			Spec: kubebatchv1.JobSpec{
+				Completions: getInt32Ptr(t, 2),
+			},
+			Status: kubebatchv1.JobStatus{
+				Succeeded: 1,
+				Active:    1,
+			},
+		},
+		&kubebatchv1.Job{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Namespace: "Foo",
+				Name:      "failed",
+			},
+			Status: kubebatchv1.JobStatus{
+				Succeeded: 1,
+				Conditions: []kubebatchv1.JobCondition{{
+					Type:   kubebatchv1.JobFailed,
+					Status: kubecorev1.ConditionTrue,
+					Reason: "BackoffLimitExceeded",
+				}},
+			},
+		},
+		&kubebatchv1.CronJob{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Namespace: "Foo",
+				Name:      "recent",
+			},
+			Status: kubebatchv1.CronJobStatus{
+				LastSuccessfulTime: &kubemetav1.Time{
+					Time: time.Now().Add(-time.Hour),
+				},
+			},
+		},
+		&kubebatchv1.CronJob{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Namespace: "Foo",
+				Name:      "stale",
+			},
+			Status: kubebatchv1.CronJobStatus{
+				LastSuccessfulTime: &kubemetav1.Time{
+					Time: time.Now().Add(-27 * time.Hour),
+				},
+			},
+		},
+		&kubebatchv1.CronJob{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Namespace: "Foo",
+				Name:      "never",
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name      string // Name of test case.
+		target    target // Target to scan.
+		expReady  bool   // Expected readiness.
+		expReason string // Expected reason.
+	}{{
+		name: "JobComplete",
+		target: target{
+			Kind: kindJob, Namespace: "Foo", Name: "complete",
+			Mode: modeAllOfThem,
+		},
+		expReady:  true,
+		expReason: "",
+	}, {
+		name: "JobRunning",
+		target: target{
+			Kind: kindJob, Namespace: "Foo", Name: "running",
+			Mode: modeAllOfThem,
+		},
+		expReady:  false,
+		expReason: "replicas not fitting mode",
+	}, {
+		name: "JobFailed",
+		target: target{
+			Kind: kindJob, Namespace: "Foo", Name: "failed",
+			Mode: modeAllOfThem,
+		},
+		expReady:  false,
+		expReason: "failed: BackoffLimitExceeded",
+	}, {
+		name: "CronJobRecent",
+		target: target{
+			Kind: kindCronJob, Namespace: "Foo", Name: "recent",
+			Mode: modeAllOfThem, MaxAge: 26 * time.Hour,
+		},
+		expReady:  true,
+		expReason: "",
+	}, {
+		name: "CronJobStale",
+		target: target{
+			Kind: kindCronJob, Namespace: "Foo", Name: "stale",
+			Mode: modeAllOfThem, MaxAge: 26 * time.Hour,
+		},
+		expReady:  false,
+		expReason: "last successful run 27h0m0s ago exceeds max age 26h0m0s",
+	}, {
+		name: "CronJobNeverSucceeded",
+		target: target{
+			Kind: kindCronJob, Namespace: "Foo", Name: "never",
+			Mode: modeAllOfThem, MaxAge: 26 * time.Hour,
+		},
+		expReady:  false,
+		expReason: "no successful run",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			scan := performScan(&performScanOptions{
+				ctx:     t.Context(),
+				log:     newLogger(t),
+				client:  kubefake.NewSimpleClientset(objects...),
+				targets: []target{tc.target},
+			})
+
+			if len(scan.results) != 1 {
+				t.Fatalf("Expected 1 result, got %v", len(scan.results))
+			}
+
+			result := scan.results[0]
+
+			if !result.success {
+				t.Errorf("Expected success, got failure: %v", result.reason)
+			}
+
+			if result.ready != tc.expReady {
+				t.Errorf(
+					"Unexpected ready: got %v, want %v",
+					result.ready,
+					tc.expReady,
+				)
+			}
+
+			if result.reason != tc.expReason {
+				t.Errorf(
+					"Unexpected reason: got %q, want %q",
+					result.reason,
+					tc.expReason,
+				)
+			}
+		})
+	}
+}
+
+// TestPerformScanNodes tests the performScan function with targets of kind
+// Node. Matched nodes are evaluated together as a single result.
+func TestPerformScanNodes(t *testing.T) {
+	objects := []kuberuntime.Object{
+		newNode(t, "a-1", map[string]string{"pool": "a"}, true),
+		newNode(t, "a-2", map[string]string{"pool": "a"}, true),
+		newNode(t, "a-3", map[string]string{"pool": "a"}, false),
+		newNode(t, "b-1", map[string]string{"pool": "b"}, false),
+		newNode(t, "c-1", map[string]string{"pool": "c"}, true),
+	}
+
+	for _, tc := range []struct {
+		name        string   // Name of test case.
+		target      target   // Target to scan.
+		expReady    bool     // Expected readiness.
+		expReplicas replicas // Expected aggregated replicas.
+	}{{
+		name: "AllOfThem",
+		target: target{
+			Kind: kindNode, LabelSelector: "pool=a", Mode: modeAllOfThem,
+		},
+		expReady:    false,
+		expReplicas: replicas{desired: 3, ready: 2, available: 2, updated: 3},
+	}, {
+		name: "MinReady",
+		target: target{
+			Kind: kindNode, LabelSelector: "pool=a", Mode: modeMinReady,
+			MinReady: 2,
+		},
+		expReady:    true,
+		expReplicas: replicas{desired: 3, ready: 2, available: 2, updated: 3},
+	}, {
+		name: "PoolLost",
+		target: target{
+			Kind: kindNode, LabelSelector: "pool=b", Mode: modeMinReady,
+			MinReady: 1,
+		},
+		expReady:    false,
+		expReplicas: replicas{desired: 1, ready: 0, available: 0, updated: 1},
+	}, {
+		// Deleted nodes are not desired anymore, so only the floor notices
+		// that the pool shrank.
+		name: "AllOfThemBelowFloor",
+		target: target{
+			Kind: kindNode, LabelSelector: "pool=c", Mode: modeAllOfThem,
+			MinReady: 2,
+		},
+		expReady:    false,
+		expReplicas: replicas{desired: 1, ready: 1, available: 1, updated: 1},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			scan := performScan(&performScanOptions{
+				ctx:     t.Context(),
+				log:     newLogger(t),
+				client:  kubefake.NewSimpleClientset(objects...),
+				targets: []target{tc.target},
+			})
+
+			if len(scan.results) != 1 {
+				t.Fatalf("Expected 1 result, got %v", len(scan.results))
+			}
+
+			result := scan.results[0]
+
+			if result.ready != tc.expReady {
+				t.Errorf(
+					"Unexpected ready: got %v, want %v",
+					result.ready,
+					tc.expReady,
+				)
+			}
+
+			if result.namespace != "" ||
+				result.name != tc.target.LabelSelector {
+				t.Errorf(
+					"Unexpected namespace and name: %q, %q",
+					result.namespace,
+					result.name,
+				)
+			}
+
+			diff := cmp.Diff(
+				tc.expReplicas, result.replicas,
+				cmp.AllowUnexported(replicas{}),
+			)
+			if diff != "" {
+				t.Errorf("Replicas mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// TestPerformScanCustomResources tests the performScan function with targets
+// that select custom resources with the dynamic client.
+func TestPerformScanCustomResources(t *testing.T) {
+	gvr := kubeschema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "certificates",
+	}
+
+	client := kubedynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		kuberuntime.NewScheme(),
+		map[kubeschema.GroupVersionResource]string{gvr: "CertificateList"},
+		newCertificate(t, "Foo", "ready", "True"),
+		newCertificate(t, "Foo", "issuing", "False"),
+		newCertificate(t, "Bar", "other", "True"),
+		newCertificate(t, "Foo", "fresh", ""),
+	)
+
+	for _, tc := range []struct {
+		name       string   // Name of test case.
+		target     target   // Target to scan.
+		expResults []string // Expected results as namespace/name=reason.
+	}{{
+		name: "Ready",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Kind:       "Certificate",
+			Resource:   "certificates",
+			Namespace:  "Foo",
+			Name:       "ready",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready=True",
+		},
+		expResults: []string{"Foo/ready="},
+	}, {
+		name: "LabelSelector",
+		target: target{
+			APIVersion:    "cert-manager.io/v1",
+			Kind:          "Certificate",
+			Resource:      "certificates",
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+			Mode:          modeAllOfThem,
+			Condition:     "Ready=True",
+		},
+		expResults: []string{
+			"Foo/fresh=condition Ready missing",
+			"Foo/issuing=condition Ready is False",
+			"Foo/ready=",
+		},
+	}, {
+		name: "ClusterWide",
+		target: target{
+			APIVersion:    "cert-manager.io/v1",
+			Kind:          "Certificate",
+			Resource:      "certificates",
+			LabelSelector: "app=x",
+			Mode:          modeAllOfThem,
+			Condition:     "Ready=False",
+		},
+		expResults: []string{
+			"Bar/other=condition Ready is True",
+			"Foo/fresh=condition Ready missing",
+			"Foo/issuing=",
+			"Foo/ready=condition Ready is True",
+		},
+	}, {
+		name: "JSONPath",
+		target: target{
+			APIVersion:    "cert-manager.io/v1",
+			Kind:          "Certificate",
+			Resource:      "certificates",
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+			Mode:          modeAllOfThem,
+			JSONPath: jsonPath{
+				Ready: "{.status.conditions[?(@.type=='Ready')].status}",
+			},
+		},
+		expResults: []string{
+			"Foo/fresh=replicas not fitting mode",
+			"Foo/issuing=replicas not fitting mode",
+			"Foo/ready=",
+		},
+	}, {
+		name: "NotFound",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Kind:       "Certificate",
+			Resource:   "certificates",
+			Namespace:  "Foo",
+			Name:       "missing",
+			Mode:       modeAllOfThem,
+			Condition:  "Ready=True",
+		},
+		expResults: []string{
+			"Foo/missing=get Certificate: " +
+				"certificates.cert-manager.io \"missing\" not found",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			scan := performScan(&performScanOptions{
+				ctx:           t.Context(),
+				log:           newLogger(t),
+				client:        kubefake.NewSimpleClientset(),
+				dynamicClient: client,
+				targets:       []target{tc.target},
+			})
+
+			gotResults := []string{}
+			for _, result := range scan.results {
+				gotResults = append(gotResults, fmt.Sprintf(
+					"%v/%v=%v", result.namespace, result.name, result.reason,
+				))
+			}
+
+			slices.Sort(gotResults)
+
+			if diff := cmp.Diff(tc.expResults, gotResults); diff != "" {
+				t.Errorf("Results mismatch (-want +got):\n%v", diff)
+			}
+
+			for _, result := range scan.results {
+				if result.ready != (result.reason == "") {
+					t.Errorf(
+						"Unexpected ready %v for reason %q",
+						result.ready,
+						result.reason,
+					)
+				}
+			}
+		})
+	}
+}
+
+// TestPerformScanExpressions tests the performScan function with targets that
+// use expressions as additional health rule.
+func TestPerformScanExpressions(t *testing.T) {
+	gvr := kubeschema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "certificates",
+	}
+
+	kubeClient := kubefake.NewSimpleClientset(
+		newDeployment(t, "Foo", "x", map[string]string{"app": "x"}, 2, 2),
+		newDeployment(t, "Foo", "y", map[string]string{"app": "x"}, 2, 1),
+		newPod(t, "Foo", "a", map[string]string{"app": "x"}, true),
+		newPod(t, "Foo", "b", map[string]string{"app": "x"}, true),
+	)
+	dynamicClient := kubedynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		kuberuntime.NewScheme(),
+		map[kubeschema.GroupVersionResource]string{gvr: "CertificateList"},
+		newCertificate(t, "Foo", "ready", "True"),
+		newCertificate(t, "Foo", "issuing", "False"),
+	)
+
+	for _, tc := range []struct {
+		name       string   // Name of test case.
+		target     target   // Target to scan.
+		expResults []string // Expected results as namespace/name=reason.
+	}{{
+		name: "Deployments",
+		target: target{
+			Kind:          kindDeployment,
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+			Mode:          modeAtLeastOne,
+			Expression:    "object.status.readyReplicas >= 2",
+		},
+		expResults: []string{"Foo/x=", "Foo/y=expression not satisfied"},
+	}, {
+		name: "EvaluationError",
+		target: target{
+			Kind:       kindDeployment,
+			Namespace:  "Foo",
+			Name:       "x",
+			Mode:       modeAllOfThem,
+			Expression: "object.status.updatedReplicas == object.spec.replicas",
+		},
+		expResults: []string{
+			"Foo/x=evaluate expression: no such key: updatedReplicas",
+		},
+	}, {
+		name: "PodsAggregated",
+		target: target{
+			Kind:          kindPod,
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+			Mode:          modeAllOfThem,
+			Expression:    "object.metadata.name != 'b'",
+		},
+		expResults: []string{"Foo/app=x=replicas not fitting mode"},
+	}, {
+		name: "CustomResources",
+		target: target{
+			APIVersion:    "cert-manager.io/v1",
+			Kind:          "Certificate",
+			Resource:      "certificates",
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+			Mode:          modeAllOfThem,
+			Expression: "object.status.conditions.exists(c, " +
+				"c.type == 'Ready' && c.status == 'True')",
+		},
+		expResults: []string{
+			"Foo/issuing=expression not satisfied",
+			"Foo/ready=",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := compileTargets([]target{tc.target})
+			if err != nil {
+				t.Fatalf("Failed to compile targets: %v", err)
+			}
+
+			scan := performScan(&performScanOptions{
+				ctx:           t.Context(),
+				log:           newLogger(t),
+				client:        kubeClient,
+				dynamicClient: dynamicClient,
+				targets:       targets,
+			})
+
+			gotResults := []string{}
+			for _, result := range scan.results {
+				gotResults = append(gotResults, fmt.Sprintf(
+					"%v/%v=%v", result.namespace, result.name, result.reason,
+				))
+			}
+
+			slices.Sort(gotResults)
+
+			if diff := cmp.Diff(tc.expResults, gotResults); diff != "" {
+				t.Errorf("Results mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// TestExtractReplicas tests that the extractReplicas function extracts
+// replica counts and booleans from custom resources with JSONPath templates.
+func TestExtractReplicas(t *testing.T) {
+	object := &kubeunstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{"replicas": int64(3)},
+		"status": map[string]any{
+			"readyReplicas": int64(2),
+			"phase":         "Running",
+			"healthy":       true,
+			"conditions": []any{
+				map[string]any{"type": "Ready", "status": "True"},
+				map[string]any{"type": "Synced", "status": "False"},
+			},
+		},
+	}}
+
+	for _, tc := range []struct {
+		name        string   // Name of test case.
+		paths       jsonPath // JSONPath templates.
+		expReplicas replicas // Expected replicas.
+		errSubstr   string   // Substring expected to be in error string.
+	}{{
+		name: "Counts",
+		paths: jsonPath{
+			Ready:   "{.status.readyReplicas}",
+			Desired: "{.spec.replicas}",
+		},
+		expReplicas: replicas{desired: 3, ready: 2, available: 2, updated: 3},
+	}, {
+		name: "CountsReadyMissing",
+		paths: jsonPath{
+			Ready:   "{.status.availableReplicas}",
+			Desired: "{.spec.replicas}",
+		},
+		expReplicas: replicas{desired: 3, ready: 0, available: 0, updated: 3},
+	}, {
+		name: "CountsDesiredMissing",
+		paths: jsonPath{
+			Ready:   "{.status.readyReplicas}",
+			Desired: "{.spec.size}",
+		},
+		errSubstr: "extract desired: no value",
+	}, {
+		name: "CountsNotNumber",
+		paths: jsonPath{
+			Ready:   "{.status.phase}",
+			Desired: "{.spec.replicas}",
+		},
+		errSubstr: "extract ready: strconv.Atoi: parsing \"Running\"",
+	}, {
+		name:        "Boolean",
+		paths:       jsonPath{Ready: "{.status.healthy}"},
+		expReplicas: replicas{desired: 1, ready: 1, available: 1, updated: 1},
+	}, {
+		name: "BooleanFromCondition",
+		paths: jsonPath{
+			Ready: "{.status.conditions[?(@.type=='Synced')].status}",
+		},
+		expReplicas: replicas{desired: 1, ready: 0, available: 0, updated: 1},
+	}, {
+		name:        "BooleanMissing",
+		paths:       jsonPath{Ready: "{.status.unknown}"},
+		expReplicas: replicas{desired: 1, ready: 0, available: 0, updated: 1},
+	}, {
+		name:      "BooleanNotBoolean",
+		paths:     jsonPath{Ready: "{.status.phase}"},
+		errSubstr: "extract ready: strconv.ParseBool: parsing \"Running\"",
+	}, {
+		name:      "MultipleValues",
+		paths:     jsonPath{Ready: "{.status.conditions[*].status}"},
+		errSubstr: "extract ready: multiple values: [True False]",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := extractReplicas(object, tc.paths)
+
+			if tc.errSubstr != "" {
+				if err == nil {
+					t.Fatalf("Expected error, got nil")
+				}
+
+				if !strings.Contains(err.Error(), tc.errSubstr) {
+					t.Errorf(
+						"Expected error to contain %q, got %q",
+						tc.errSubstr,
+						err,
+					)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			diff := cmp.Diff(
+				tc.expReplicas, got, cmp.AllowUnexported(replicas{}),
+			)
+			if diff != "" {
+				t.Errorf("Replicas mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// TestPerformScanInformers tests the performScan function with an informer
+// cache. Results must match the ones of a scan against the Kubernetes API and
+// changes must become visible without new requests.
+func TestPerformScanInformers(t *testing.T) {
+	gvr := kubeschema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "certificates",
+	}
+
+	kubeClient := kubefake.NewSimpleClientset(
+		&kubecorev1.Namespace{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Name:   "Foo",
+				Labels: map[string]string{"team": "a"},
+			},
+		},
+		&kubecorev1.Namespace{
+			ObjectMeta: kubemetav1.ObjectMeta{
+				Name:   "Bar",
+				Labels: map[string]string{"team": "a"},
+			},
+		},
+		newDeployment(t, "Foo", "x", map[string]string{"app": "x"}, 2, 2),
+		newDeployment(t, "Foo", "y", map[string]string{"app": "x"}, 2, 1),
+		newDeployment(t, "Bar", "x", map[string]string{"app": "x"}, 1, 1),
+		newPod(t, "Foo", "a", map[string]string{"app": "x"}, true),
+		newNode(t, "a-1", map[string]string{"pool": "a"}, true),
+	)
+	clusterIssuerGVR := kubeschema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "clusterissuers",
+	}
+
+	clusterIssuer := newCertificate(t, "", "letsencrypt", "True")
+	clusterIssuer.SetKind("ClusterIssuer")
+
+	dynamicClient := kubedynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		kuberuntime.NewScheme(),
+		map[kubeschema.GroupVersionResource]string{
+			gvr:              "CertificateList",
+			clusterIssuerGVR: "ClusterIssuerList",
+		},
+		newCertificate(t, "Foo", "ready", "True"),
+		newCertificate(t, "Foo", "issuing", "False"),
+		clusterIssuer,
+	)
+
+	targets := []target{{
+		Kind:              kindDeployment,
+		NamespaceSelector: "team=a",
+		LabelSelector:     "app=x",
+		Mode:              modeAllOfThem,
+	}, {
+		Kind:      kindDeployment,
+		Namespace: "Foo",
+		Name:      "x",
+		Mode:      modeAllOfThem,
+	}, {
+		Kind:          kindPod,
+		Namespace:     "Foo",
+		LabelSelector: "app=x",
+		Mode:          modeAllOfThem,
+	}, {
+		Kind:          kindNode,
+		LabelSelector: "pool=a",
+		Mode:          modeAllOfThem,
+	}, {
+		APIVersion:    "cert-manager.io/v1",
+		Kind:          "Certificate",
+		Resource:      "certificates",
+		Namespace:     "Foo",
+		LabelSelector: "app=x",
+		Mode:          modeAllOfThem,
+		Condition:     "Ready=True",
+	}, {
+		APIVersion: "cert-manager.io/v1",
+		Kind:       "ClusterIssuer",
+		Resource:   "clusterissuers",
+		Name:       "letsencrypt",
+		Mode:       modeAllOfThem,
+		Condition:  "Ready=True",
+	}, {
+		Kind:      kindDeployment,
+		Namespace: "Foo",
+		Name:      "missing",
+		Mode:      modeAllOfThem,
+	}}
+
+	cache, err := newInformerCache(
+		t.Context(), kubeClient, dynamicClient, targets,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create informer cache: %v", err)
+	}
+
+	formatResults := func(scan scan) []string {
+		results := []string{}
+		for _, result := range scan.results {
+			results = append(results, fmt.Sprintf(
+				"%v/%v/%v=%v",
+				result.kind, result.namespace, result.name, result.ready,
+			))
+		}
+
+		return results
+	}
+
+	apiScan := performScan(&performScanOptions{
+		ctx:           t.Context(),
+		log:           newLogger(t),
+		client:        kubeClient,
+		dynamicClient: dynamicClient,
+		targets:       targets,
+	})
+
+	kubeClient.ClearActions()
+	dynamicClient.ClearActions()
+
+	cacheScan := performScan(&performScanOptions{
+		ctx:           t.Context(),
+		log:           newLogger(t),
+		client:        kubeClient,
+		dynamicClient: dynamicClient,
+		cache:         cache,
+		targets:       targets,
+	})
+
+	diff := cmp.Diff(formatResults(apiScan), formatResults(cacheScan))
+	if diff != "" {
+		t.Fatalf("Results mismatch (-api +cache):\n%v", diff)
+	}
+
+	if !slices.Contains(
+		formatResults(cacheScan), "ClusterIssuer//letsencrypt=true",
+	) {
+		t.Errorf("Cluster-scoped resource not found: %v", cacheScan.results)
+	}
+
+	for _, action := range slices.Concat(
+		kubeClient.Actions(), dynamicClient.Actions(),
+	) {
+		if action.GetVerb() == "get" || action.GetVerb() == "list" {
+			t.Errorf(
+				"Unexpected API request with cache: %v %v",
+				action.GetVerb(),
+				action.GetResource().Resource,
+			)
+		}
+	}
+
+	_, err = kubeClient.AppsV1().Deployments("Foo").Update(
+		t.Context(),
+		newDeployment(t, "Foo", "x", map[string]string{"app": "x"}, 2, 0),
+		kubemetav1.UpdateOptions{},
+	)
+	if err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		scan := performScan(&performScanOptions{
+			ctx:           t.Context(),
+			log:           newLogger(t),
+			client:        kubeClient,
+			dynamicClient: dynamicClient,
+			cache:         cache,
+			targets:       targets[1:2],
+		})
+
+		if !scan.results[0].ready {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Update not visible in cache")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestInformerCacheScopes tests that informers only list and watch the
+// namespace and labels selected by the targets.
+func TestInformerCacheScopes(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+
+	_, err := newInformerCache(
+		t.Context(), kubeClient, kubedynamicfake.NewSimpleDynamicClient(
+			kuberuntime.NewScheme(),
+		),
+		[]target{{
+			Kind:          kindPod,
+			Namespace:     "Foo",
+			LabelSelector: "app=x",
+		}, {
+			Kind:      kindDeployment,
+			Namespace: "Foo",
+			Name:      "x",
+		}, {
+			Kind:              kindDeployment,
+			NamespaceSelector: "team=a",
+			LabelSelector:     "app=x",
+		}, {
+			Kind:          kindNode,
+			LabelSelector: "pool=a",
+		}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create informer cache: %v", err)
+	}
+
+	lists := []string{}
+
+	for _, action := range kubeClient.Actions() {
+		listAction, ok := action.(kubetesting.ListAction)
+		if !ok {
+			continue
+		}
+
+		lists = append(lists, fmt.Sprintf(
+			"%v/%v/%v",
+			listAction.GetResource().Resource,
+			listAction.GetNamespace(),
+			listAction.GetListRestrictions().Labels,
+		))
+	}
+
+	slices.Sort(lists)
+
+	want := []string{
+		"deployments//app=x",
+		"deployments/Foo/",
+		"namespaces//team=a",
+		"nodes//pool=a",
+		"pods/Foo/app=x",
+	}
+
+	if diff := cmp.Diff(want, lists); diff != "" {
+		t.Errorf("Lists mismatch (-want +got):\n%v", diff)
+	}
+}
+
+// newCertificate creates a cert-manager certificate as unstructured object
+// with the label "app=x" and the given status of the "Ready" condition. If the
+// status is empty, the certificate has no conditions.
+func newCertificate(
+	t *testing.T,
+	namespace string,
+	name string,
+	readyStatus string,
+) *kubeunstructured.Unstructured {
+	t.Helper()
+
+	object := &kubeunstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]any{
+			"namespace": namespace,
+			"name":      name,
+			"labels":    map[string]any{"app": "x"},
+		},
+	}}
+
+	if readyStatus != "" {
+		object.Object["status"] = map[string]any{
+			"conditions": []any{
+				map[string]any{"type": "Issuing", "status": "False"},
+				map[string]any{"type": "Ready", "status": readyStatus},
+			},
+		}
+	}
+
+	return object
+}
+
+// newDeployment creates a deployment with the given labels and replicas.
+func newDeployment(
+	t *testing.T,
+	namespace string,
+	name string,
+	labels map[string]string,
+	replicas int32,
+	readyReplicas int32,
+) *kubeappsv1.Deployment {
+	t.Helper()
+
+	return &kubeappsv1.Deployment{
+		ObjectMeta: kubemetav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Spec: kubeappsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+		Status: kubeappsv1.DeploymentStatus{
+			Replicas:      replicas,
+			ReadyReplicas: readyReplicas,
+		},
+	}
+}
+
+// newPod creates a pod with the given labels and ready condition.
+func newPod(
+	t *testing.T,
+	namespace string,
+	name string,
+	labels map[string]string,
+	ready bool,
+) *kubecorev1.Pod {
+	t.Helper()
+
+	status := kubecorev1.ConditionFalse
+	if ready {
+		status = kubecorev1.ConditionTrue
+	}
+
+	return &kubecorev1.Pod{
+		ObjectMeta: kubemetav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Status: kubecorev1.PodStatus{
+			Conditions: []kubecorev1.PodCondition{{
+				Type:   kubecorev1.PodReady,
+				Status: status,
+			}},
+		},
+	}
+}
+
+// newNode creates a node with the given labels and ready condition.
+func newNode(
+	t *testing.T,
+	name string,
+	labels map[string]string,
+	ready bool,
+) *kubecorev1.Node {
+	t.Helper()
+
+	status := kubecorev1.ConditionFalse
+	if ready {
+		status = kubecorev1.ConditionTrue
+	}
+
+	return &kubecorev1.Node{
+		ObjectMeta: kubemetav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: kubecorev1.NodeStatus{
+			Conditions: []kubecorev1.NodeCondition{{
+				Type:   kubecorev1.NodeReady,
+				Status: status,
+			}},
+		},
+	}
+}
+
+// cwPutMetricDataImpl implements CWPutMetricDataAPI. Based on this example:
+// https://github.com/awsdocs/aws-doc-sdk-examples/tree/main/gov2/cloudwatch/CreateCustomMetric
+// Successful inputs are recorded for later inspection. If calls is set, they
+// are also sent to the channel. Like the real client, it fails if the context
+// is already canceled. The first failFirst calls fail as well, all calls are
+// counted in attempts.
+type cwPutMetricDataImpl struct {
+	returnError bool
+	failFirst   int
+	attempts    int
+	inputs      []*cw.PutMetricDataInput
+	calls       chan *cw.PutMetricDataInput
+}
+
+// PutMetricData implements CWPutMetricDataAPI. Based on this example:
+// https://github.com/awsdocs/aws-doc-sdk-examples/tree/main/gov2/cloudwatch/CreateCustomMetric
+func (dt *cwPutMetricDataImpl) PutMetricData(
+	ctx context.Context,
+	params *cw.PutMetricDataInput,
+	_ ...func(*cw.Options),
+) (*cw.PutMetricDataOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return &cw.PutMetricDataOutput{}, fmt.Errorf("fake error: %v", err)
+	}
+
+	dt.attempts++
+
+	if dt.returnError || dt.attempts <= dt.failFirst {
+		return &cw.PutMetricDataOutput{}, fmt.Errorf("fake error")
+	}
+
+	dt.inputs = append(dt.inputs, params)
+
+	if dt.calls != nil {
+		dt.calls <- params
+	}
+
+	return &cw.PutMetricDataOutput{}, nil
+}
+
+// TestPerformScanConcurrency tests that performScan scans targets
+// concurrently, keeps the order of the targets in the results, and turns
+// targets that exceed the timeout into failed results. Parallelism is
+// asserted with a barrier that only opens once all gated targets are scanned
+// at the same time.
+func TestPerformScanConcurrency(t *testing.T) {
+	targets := []target{}
+	for _, name := range []string{"slow", "a", "b", "c"} {
+		targets = append(targets, target{
+			Kind:      kindDeployment,
+			Namespace: "Foo",
+			Name:      name,
+			Mode:      modeAllOfThem,
+		})
+	}
+
+	// Blocks until the context is done. Used for the slow target.
+	block := func(ctx context.Context, name string) error {
+		if name != "slow" {
+			return nil
+		}
+
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	for _, tc := range []struct {
+		name      string          // Name of test case.
+		workers   int             // Number of workers.
+		timeout   time.Duration   // Timeout per target.
+		newGate   func() gateFunc // Creates gate of Get calls.
+		expReady  bool            // Expected readiness of slow target.
+		expReason string          // Expected reason of slow target.
+	}{{
+		name:    "Parallel",
+		workers: 4,
+		timeout: 0,
+		newGate: func() gateFunc {
+			return newBarrier("slow", "a", "b", "c")
+		},
+		expReady:  true,
+		expReason: "",
+	}, {
+		name:    "NoTimeout",
+		workers: 2,
+		timeout: 0,
+		newGate: func() gateFunc {
+			return newBarrier("slow", "a")
+		},
+		expReady:  true,
+		expReason: "",
+	}, {
+		name:    "SingleWorker",
+		workers: 1,
+		timeout: time.Minute,
+		newGate: func() gateFunc {
+			return func(context.Context, string) error { return nil }
+		},
+		expReady:  true,
+		expReason: "",
+	}, {
+		name:    "Timeout",
+		workers: 2,
+		timeout: 50 * time.Millisecond,
+		newGate: func() gateFunc {
+			return block
+		},
+		expReady:  false,
+		expReason: "scan timed out after 50ms",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := kubefake.NewSimpleClientset(
+				newDeployment(t, "Foo", "slow", nil, 1, 1),
+				newDeployment(t, "Foo", "a", nil, 1, 1),
+				newDeployment(t, "Foo", "b", nil, 1, 1),
+				newDeployment(t, "Foo", "c", nil, 1, 1),
+			)
+
+			scan := performScan(&performScanOptions{
+				ctx:     t.Context(),
+				log:     newLogger(t),
+				client:  gatedClient{Interface: client, gate: tc.newGate()},
+				workers: tc.workers,
+				timeout: tc.timeout,
+				targets: targets,
+			})
+
+			names := []string{}
+			for _, result := range scan.results {
+				names = append(names, result.name)
+			}
+
+			if diff := cmp.Diff(
+				[]string{"slow", "a", "b", "c"}, names,
+			); diff != "" {
+				t.Errorf("Unexpected result order (-want +got):\n%s", diff)
+			}
+
+			slow := scan.results[0]
+
+			if slow.ready != tc.expReady || slow.reason != tc.expReason {
+				t.Errorf(
+					"Unexpected slow result: got %v %q, want %v %q",
+					slow.ready, slow.reason, tc.expReady, tc.expReason,
+				)
+			}
+
+			if slow.success != tc.expReady {
+				t.Errorf("Unexpected success of slow result: %v", slow.success)
+			}
+
+			for _, result := range scan.results[1:] {
+				if !result.ready {
+					t.Errorf(
+						"Expected %v to be ready: %v",
+						result.name, result.reason,
+					)
+				}
+			}
+		})
+	}
+}
+
+// Maximum duration to wait for all targets to arrive at a barrier.
+const barrierTimeout = 5 * time.Second
+
+// newBarrier returns a gate that blocks Get calls for the given names until
+// all of them have arrived. Calls for other names pass. If not all names
+// arrive in time, the calls fail, which shows that they did not run in
+// parallel.
+func newBarrier(names ...string) gateFunc {
+	var (
+		mu      sync.Mutex
+		arrived int
+	)
+
+	all := make(chan struct{})
+
+	return func(ctx context.Context, name string) error {
+		if !slices.Contains(names, name) {
+			return nil
+		}
+
+		mu.Lock()
+		arrived++
+
+		if arrived == len(names) {
+			close(all)
+		}
+		mu.Unlock()
+
+		select {
+		case <-all:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(barrierTimeout):
+			return fmt.Errorf("%v not scanned in parallel with %v", name, names)
+		}
+	}
+}
+
+// gateFunc is called before a Get with the name of the object. Returning an
+// error fails the Get.
+type gateFunc func(ctx context.Context, name string) error
+
+// gatedClient wraps a Kubernetes client so that every Get of a deployment
+// passes the gate first. Unlike reactors of the fake client, the gate does
+// not hold the lock of the fake client while it blocks.
+type gatedClient struct {
+	kube.Interface
+
+	gate gateFunc
+}
+
+// AppsV1 returns the gated apps client.
+func (c gatedClient) AppsV1() kubeappsv1client.AppsV1Interface {
+	return gatedAppsClient{AppsV1Interface: c.Interface.AppsV1(), gate: c.gate}
+}
+
+// gatedAppsClient is the apps client of gatedClient.
+type gatedAppsClient struct {
+	kubeappsv1client.AppsV1Interface
+
+	gate gateFunc
+}
+
+// Deployments returns the gated deployment client.
+func (c gatedAppsClient) Deployments(
+	namespace string,
+) kubeappsv1client.DeploymentInterface {
+	return gatedDeploymentClient{
+		DeploymentInterface: c.AppsV1Interface.Deployments(namespace),
+		gate:                c.gate,
+	}
+}
+
+// gatedDeploymentClient is the deployment client of gatedClient.
+type gatedDeploymentClient struct {
+	kubeappsv1client.DeploymentInterface
+
+	gate gateFunc
+}
+
+// Get passes the gate and then gets the deployment.
+func (c gatedDeploymentClient) Get(
+	ctx context.Context,
+	name string,
+	options kubemetav1.GetOptions,
+) (*kubeappsv1.Deployment, error) {
+	if err := c.gate(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return c.DeploymentInterface.Get(ctx, name, options)
+}
+
+// TestUpdateMetric tests the updateMetric function.
+func TestUpdateMetric(t *testing.T) {
+	for _, tc := range []struct {
+		name        string  // Name of test case.
+		value       float64 // Value of metric.
+		dryRun      bool    // Enable dry run mode.
+		returnError bool    // Should the mock return an error?
+		expSuccess  bool    // Is the call expected to succeed?
+	}{{
+		name:        "SuccessOne",
+		value:       1,
+		returnError: false,
+		expSuccess:  true,
+	}, {
+		name:        "SuccessZero",
+		value:       0,
+		returnError: false,
+		expSuccess:  true,
+	}, {
+		name:        "Failure",
+		value:       0,
+		returnError: true,
+		expSuccess:  false,
+	}, {
+		name:        "DryFailure",
+		value:       1,
+		dryRun:      true,
+		returnError: true,
+		expSuccess:  true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := updateMetric(&updateMetricOptions{
+				ctx:    t.Context(),
+				dry:    tc.dryRun,
+				client: &cwPutMetricDataImpl{returnError: tc.returnError},
+				updates: []metricUpdate{{
+					metric: metric{
+						Namespace: "MyNamespace",
+						Name:      "MyMetric",
+						Dimensions: []dimension{
+							{Name: "Cluster", Value: "MyCluster"},
+						},
+					},
+					value: tc.value,
+				}},
+			})
+
+			if tc.expSuccess && err != nil {
+				t.Errorf("Unexpected failure: %v", err)
+			}
+
+			if !tc.expSuccess && err == nil {
+				t.Errorf("Unexpected success")
+			}
+		})
+	}
+
+	t.Run("SuccessNilDimensions", func(t *testing.T) {
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			dry:    false,
+			client: &cwPutMetricDataImpl{returnError: false},
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace:  "MyNamespace",
+					Name:       "MyMetric",
+					Dimensions: nil,
+				},
+				value: 1,
+			}},
+		})
+		if err != nil {
+			t.Errorf("Unexpected failure: %v", err)
+		}
+	})
+
+	t.Run("PerTarget", func(t *testing.T) {
+		client := &cwPutMetricDataImpl{returnError: false}
+
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			dry:    false,
+			client: client,
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace: "MyNamespace",
+					Name:      "MyMetric",
+					Dimensions: []dimension{
+						{Name: "Cluster", Value: "MyCluster"},
+					},
+					PerTarget: true,
+				},
+				value: 0,
+				results: []result{{
+					success:   true,
+					ready:     true,
+					kind:      kindDeployment,
+					namespace: "Foo",
+					name:      "Bar",
+				}, {
+					success:   true,
+					ready:     false,
+					kind:      kindStatefulSet,
+					namespace: "Foo",
+					name:      "Baz",
+				}, {
+					success:   true,
+					ready:     true,
+					kind:      kindNode,
+					namespace: "",
+					name:      "pool=a",
+				}},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		if len(client.inputs) != 1 {
+			t.Fatalf(
+				"Unexpected number of calls: got %v, want %v",
+				len(client.inputs),
+				1,
+			)
+		}
+
+		metricData := client.inputs[0].MetricData
+
+		if len(metricData) != 4 {
+			t.Fatalf(
+				"Unexpected number of datums: got %v, want %v",
+				len(metricData),
+				4,
+			)
+		}
+
+		for i, want := range []struct {
+			value      float64
+			dimensions []string
+		}{{
+			value:      0,
+			dimensions: []string{"Cluster=MyCluster"},
+		}, {
+			value: 1,
+			dimensions: []string{
+				"Cluster=MyCluster",
+				"TargetKind=Deployment",
+				"TargetNamespace=Foo",
+				"TargetName=Bar",
+			},
+		}, {
+			value: 0,
+			dimensions: []string{
+				"Cluster=MyCluster",
+				"TargetKind=StatefulSet",
+				"TargetNamespace=Foo",
+				"TargetName=Baz",
+			},
+		}, {
+			value: 1,
+			dimensions: []string{
+				"Cluster=MyCluster",
+				"TargetKind=Node",
+				"TargetName=pool=a",
+			},
+		}} {
+			if *metricData[i].Value != want.value {
+				t.Errorf(
+					"Unexpected value for datum %v: got %v, want %v",
+					i,
+					*metricData[i].Value,
+					want.value,
+				)
+			}
+
+			gotDimensions := []string{}
+			for _, d := range metricData[i].Dimensions {
+				gotDimensions = append(gotDimensions, *d.Name+"="+*d.Value)
+			}
+
+			if diff := cmp.Diff(want.dimensions, gotDimensions); diff != "" {
+				t.Errorf(
+					"Dimensions mismatch for datum %v (-want +got):\n%v",
+					i,
+					diff,
+				)
+			}
+		}
+	})
+
+	t.Run("ReplicaCounts", func(t *testing.T) {
+		client := &cwPutMetricDataImpl{returnError: false}
+
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			dry:    false,
+			client: client,
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace:     "MyNamespace",
+					Name:          "MyMetric",
+					Dimensions:    nil,
+					PerTarget:     false,
+					ReplicaCounts: true,
+				},
+				value: 0,
+				results: []result{{
+					success:   true,
+					ready:     false,
+					kind:      kindDeployment,
+					namespace: "Foo",
+					name:      "Bar",
+					replicas:  replicas{desired: 4, ready: 3},
+				}, {
+					success:   false,
+					ready:     false,
+					kind:      kindStatefulSet,
+					namespace: "Foo",
+					name:      "Baz",
+				}, {
+					success:   true,
+					ready:     true,
+					kind:      kindDaemonSet,
+					namespace: "Foo",
+					name:      "Qux",
+					replicas:  replicas{desired: 0, ready: 0},
+				}},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		gotData := []string{}
+		for _, datum := range client.inputs[0].MetricData {
+			target := "-"
+			if len(datum.Dimensions) > 0 {
+				target = *datum.Dimensions[len(datum.Dimensions)-1].Value
+			}
+
+			gotData = append(gotData, fmt.Sprintf(
+				"%v/%v=%v%v",
+				target, *datum.MetricName, *datum.Value, datum.Unit,
+			))
+		}
+
+		wantData := []string{
+			"-/MyMetric=0None",
+			"Bar/ReadyReplicas=3Count",
+			"Bar/DesiredReplicas=4Count",
+			"Bar/ReadyRatio=0.75None",
+			"Qux/ReadyReplicas=0Count",
+			"Qux/DesiredReplicas=0Count",
+			"Qux/ReadyRatio=1None",
+		}
+
+		if diff := cmp.Diff(wantData, gotData); diff != "" {
+			t.Errorf("Datums mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		for _, tc := range []struct {
+			name        string // Name of test case.
+			attempts    int    // Configured attempts.
+			failFirst   int    // Number of calls that fail.
+			expSuccess  bool   // Is the call expected to succeed?
+			expAttempts int    // Expected number of calls.
+		}{{
+			name:        "SuccessAfterRetry",
+			attempts:    3,
+			failFirst:   2,
+			expSuccess:  true,
+			expAttempts: 3,
+		}, {
+			name:        "AttemptsExhausted",
+			attempts:    2,
+			failFirst:   2,
+			expSuccess:  false,
+			expAttempts: 2,
+		}, {
+			name:        "NoAttemptsConfigured",
+			attempts:    0,
+			failFirst:   1,
+			expSuccess:  false,
+			expAttempts: 1,
+		}} {
+			t.Run(tc.name, func(t *testing.T) {
+				client := &cwPutMetricDataImpl{failFirst: tc.failFirst}
+
+				err := updateMetric(&updateMetricOptions{
+					ctx:    t.Context(),
+					log:    newLogger(t),
+					client: client,
+					retry: retry{
+						Attempts:       tc.attempts,
+						InitialBackoff: time.Millisecond,
+						MaxBackoff:     time.Millisecond,
+					},
+					updates: []metricUpdate{{
+						metric: metric{
+							Namespace: "MyNamespace",
+							Name:      "MyMetric",
+						},
+						value: 1,
+					}},
+				})
+
+				if tc.expSuccess && err != nil {
+					t.Errorf("Unexpected failure: %v", err)
+				}
+
+				if !tc.expSuccess && err == nil {
+					t.Errorf("Unexpected success")
+				}
+
+				if client.attempts != tc.expAttempts {
+					t.Errorf(
+						"Unexpected attempts: got %v, want %v",
+						client.attempts,
+						tc.expAttempts,
+					)
+				}
+			})
+		}
+	})
+
+	t.Run("TimestampAndStorageResolution", func(t *testing.T) {
+		client := &cwPutMetricDataImpl{returnError: false}
+		timestamp := time.Now().Add(-time.Minute)
+
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			client: client,
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace:         "MyNamespace",
+					Name:              "MyMetric",
+					StorageResolution: storageResolutionHigh,
+				},
+				value:     1,
+				timestamp: timestamp,
+				failures:  1,
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		for _, datum := range client.inputs[0].MetricData {
+			if datum.Timestamp == nil || !datum.Timestamp.Equal(timestamp) {
+				t.Errorf("Unexpected timestamp: %v", datum.Timestamp)
+			}
+
+			if datum.StorageResolution == nil ||
+				*datum.StorageResolution != storageResolutionHigh {
+				t.Errorf(
+					"Unexpected storage resolution: %v",
+					datum.StorageResolution,
+				)
+			}
+		}
+	})
+
+	t.Run("FailedUpdates", func(t *testing.T) {
+		client := &cwPutMetricDataImpl{returnError: false}
+
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			client: client,
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace: "MyNamespace",
+					Name:      "MyMetric",
+				},
+				value:    1,
+				failures: 2,
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		gotData := []string{}
+		for _, datum := range client.inputs[0].MetricData {
+			gotData = append(gotData, fmt.Sprintf(
+				"%v=%v%v", *datum.MetricName, *datum.Value, datum.Unit,
+			))
+		}
+
+		wantData := []string{"MyMetric=1None", "FailedUpdates=2Count"}
+
+		if diff := cmp.Diff(wantData, gotData); diff != "" {
+			t.Errorf("Datums mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("MultipleUpdates", func(t *testing.T) {
+		client := &cwPutMetricDataImpl{returnError: false}
+
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			client: client,
+			updates: []metricUpdate{{
+				metric: metric{Namespace: "A", Name: "First"},
+				value:  1,
+			}, {
+				metric: metric{Namespace: "B", Name: "Second"},
+				value:  0,
+			}, {
+				metric: metric{Namespace: "A", Name: "Third"},
+				value:  1,
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		gotCalls := []string{}
+		for _, input := range client.inputs {
+			names := []string{}
+			for _, datum := range input.MetricData {
+				names = append(names, *datum.MetricName)
+			}
+
+			gotCalls = append(gotCalls, fmt.Sprintf(
+				"%v=%v", *input.Namespace, strings.Join(names, ","),
+			))
+		}
+
+		wantCalls := []string{"A=First,Third", "B=Second"}
+
+		if diff := cmp.Diff(wantCalls, gotCalls); diff != "" {
+			t.Errorf("Calls mismatch (-want +got):\n%v", diff)
+		}
+	})
+}
+
+// TestUpdateMetricBuffer tests that updateMetric buffers datums of failed
+// calls with their original timestamp and flushes them after the next
+// successful call.
+func TestUpdateMetricBuffer(t *testing.T) {
+	datums, err := newDatumBuffer(newLogger(t), 10, "")
+	if err != nil {
+		t.Fatalf("Failed to create datum buffer: %v", err)
+	}
+
+	client := &cwPutMetricDataImpl{failFirst: 2}
+
+	update := func(value float64) error {
+		return updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			log:    newLogger(t),
+			client: client,
+			buffer: datums,
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace: "MyNamespace",
+					Name:      "MyMetric",
+				},
+				value: value,
+			}},
+		})
+	}
+
+	before := time.Now()
+
+	for _, value := range []float64{0, 0} {
+		if err := update(value); err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+	}
+
+	if datums.len() != 2 {
+		t.Fatalf("Unexpected buffered datums: got %v, want 2", datums.len())
+	}
+
+	for _, buffered := range datums.datums {
+		if buffered.Datum.Timestamp == nil ||
+			buffered.Datum.Timestamp.Before(before) {
+			t.Errorf("Unexpected timestamp: %v", buffered.Datum.Timestamp)
+		}
+	}
+
+	if err := update(1); err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+
+	if datums.len() != 0 {
+		t.Errorf("Unexpected buffered datums: got %v, want 0", datums.len())
+	}
+
+	gotCalls := []int{}
+	for _, input := range client.inputs {
+		gotCalls = append(gotCalls, len(input.MetricData))
+	}
+
+	// Current datum first, then the backfill of both buffered datums.
+	if diff := cmp.Diff([]int{1, 2}, gotCalls); diff != "" {
+		t.Errorf("Calls mismatch (-want +got):\n%v", diff)
+	}
+}
+
+// TestDatumBuffer tests bounding, batching, pruning, and persistence of the
+// datum buffer.
+func TestDatumBuffer(t *testing.T) {
+	newData := func(count int, timestamp time.Time) []cwtypes.MetricDatum {
+		data := []cwtypes.MetricDatum{}
+		for i := range count {
+			datum := newMetricDatum("MyMetric", nil, float64(i), "")
+			datum.Timestamp = &timestamp
+			data = append(data, datum)
+		}
+
+		return data
+	}
+
+	t.Run("DropOldest", func(t *testing.T) {
+		datums, err := newDatumBuffer(newLogger(t), 3, "")
+		if err != nil {
+			t.Fatalf("Failed to create datum buffer: %v", err)
+		}
+
+		datums.add("MyNamespace", newData(5, time.Now()))
+
+		values := []float64{}
+		for _, buffered := range datums.datums {
+			values = append(values, *buffered.Datum.Value)
+		}
+
+		if diff := cmp.Diff([]float64{2, 3, 4}, values); diff != "" {
+			t.Errorf("Values mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("FlushBatches", func(t *testing.T) {
+		datums, err := newDatumBuffer(newLogger(t), 5000, "")
+		if err != nil {
+			t.Fatalf("Failed to create datum buffer: %v", err)
+		}
+
+		datums.add("A", newData(maxDatumsPerCall+1, time.Now()))
+		datums.add("B", newData(2, time.Now()))
+
+		client := &cwPutMetricDataImpl{}
+
+		if err := datums.flush(t.Context(), client); err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		gotCalls := []string{}
+		for _, input := range client.inputs {
+			gotCalls = append(gotCalls, fmt.Sprintf(
+				"%v=%v", *input.Namespace, len(input.MetricData),
+			))
+		}
+
+		wantCalls := []string{"A=1000", "A=1", "B=2"}
+
+		if diff := cmp.Diff(wantCalls, gotCalls); diff != "" {
+			t.Errorf("Calls mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("FlushFailure", func(t *testing.T) {
+		datums, err := newDatumBuffer(newLogger(t), 5000, "")
+		if err != nil {
+			t.Fatalf("Failed to create datum buffer: %v", err)
+		}
+
+		datums.add("A", newData(2, time.Now()))
+		datums.add("B", newData(2, time.Now()))
+
+		client := &cwPutMetricDataImpl{failFirst: 1}
+
+		if err := datums.flush(t.Context(), client); err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		if datums.len() != 4 {
+			t.Errorf("Unexpected remaining: got %v, want 4", datums.len())
+		}
+
+		if err := datums.flush(t.Context(), client); err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		if datums.len() != 0 {
+			t.Errorf("Unexpected remaining: got %v, want 0", datums.len())
+		}
+	})
+
+	t.Run("PruneTooOld", func(t *testing.T) {
+		datums, err := newDatumBuffer(newLogger(t), 10, "")
+		if err != nil {
+			t.Fatalf("Failed to create datum buffer: %v", err)
+		}
+
+		datums.add("A", newData(2, time.Now().Add(-15*24*time.Hour)))
+		datums.add("A", newData(1, time.Now()))
+
+		client := &cwPutMetricDataImpl{}
+
+		if err := datums.flush(t.Context(), client); err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		if len(client.inputs) != 1 || len(client.inputs[0].MetricData) != 1 {
+			t.Errorf("Expected a single call with one datum")
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "buffer.json")
+
+		datums, err := newDatumBuffer(newLogger(t), 10, path)
+		if err != nil {
+			t.Fatalf("Failed to create datum buffer: %v", err)
+		}
+
+		timestamp := time.Now().Truncate(time.Second)
+		datums.add("A", newData(2, timestamp))
+
+		loaded, err := newDatumBuffer(newLogger(t), 10, path)
+		if err != nil {
+			t.Fatalf("Failed to load datum buffer: %v", err)
+		}
+
+		if loaded.len() != 2 {
+			t.Fatalf("Unexpected loaded datums: got %v, want 2", loaded.len())
+		}
+
+		datum := loaded.datums[1]
+
+		if datum.Namespace != "A" ||
+			*datum.Datum.Value != 1 ||
+			!datum.Datum.Timestamp.Equal(timestamp) {
+			t.Errorf("Unexpected loaded datum: %+v", datum)
+		}
+	})
+
+	t.Run("CorruptFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "buffer.json")
+
+		err := os.WriteFile(path, []byte("{"), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		_, err = newDatumBuffer(newLogger(t), 10, path)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "unmarshal buffer file"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+}
+
+// TestNewBackoff tests that the backoff grows exponentially up to the
+// maximum and that jitter keeps it between half and the full backoff.
+func TestNewBackoff(t *testing.T) {
+	retry := retry{
+		Attempts:       10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     8 * time.Second,
+	}
+
+	for attempt, full := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		9: 8 * time.Second,
+	} {
+		for range 100 {
+			backoff := newBackoff(retry, attempt)
+			if backoff < full/2 || backoff > full {
+				t.Fatalf(
+					"Unexpected backoff for attempt %v: got %v, want %v to %v",
+					attempt, backoff, full/2, full,
+				)
+			}
+		}
+	}
+}
+
+// newTestSinks returns a single fatal metric sink with the given client.
+func newTestSinks(client cwPutMetricDataAPI, retry retry) []outputSink {
+	return []outputSink{{
+		name: outputTypeCloudWatch,
+		sink: &metricSink{
+			log:    slog.New(slog.DiscardHandler),
+			client: client,
+			retry:  retry,
+		},
+		fatal: true,
+	}}
+}
+
+// TestExecuteRounds tests the executeRounds function.
+func TestExecuteRounds(t *testing.T) {
+	for _, tc := range []struct {
+		name    string // Name of test case.
+		cwError bool   // Should the mock return an error?
+	}{{
+		name:    "CloudwatchSuccess",
+		cwError: false,
+	}, {
+		name:    "CloudwatchFailure",
+		cwError: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			log := newLogger(t)
+
+			health := newProbes(time.Minute, time.Minute)
+			health.markVerified()
+
+			err := executeRounds(&executeRoundsOptions{
+				ctx:     t.Context(),
+				log:     log,
+				kClient: kubefake.NewSimpleClientset(),
+				sinks: newTestSinks(
+					&cwPutMetricDataImpl{returnError: tc.cwError}, retry{},
+				),
+				single:  true,
+				seconds: 1,
+				probes:  health,
+				checks: []check{{
+					Name: "default",
+					Metric: metric{
+						Namespace:  "Namespace",
+						Name:       "Name",
+						Dimensions: []dimension{},
+					},
+					Targets: []target{{
+						Kind:      kindDeployment,
+						Mode:      modeAllOfThem,
+						Namespace: "Namespace",
+						Name:      "Name",
+					}},
+				}},
+			})
+
+			if tc.cwError && err == nil {
+				t.Errorf("Expected failure, got success")
+			}
+
+			if !tc.cwError && err != nil {
+				t.Errorf("Unexpected failure: %v", err)
+			}
+
+			if ready := health.ready() == nil; ready == tc.cwError {
+				t.Errorf("Unexpected readiness: %v", ready)
+			}
+		})
+	}
+
+	t.Run("ContextCancel", func(t *testing.T) {
+		log := newLogger(t)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		err := executeRounds(&executeRoundsOptions{
+			ctx:     ctx,
+			log:     log,
+			kClient: kubefake.NewSimpleClientset(),
+			sinks: newTestSinks(
+				&cwPutMetricDataImpl{returnError: false}, retry{},
+			),
+			single:  false,
+			seconds: 1,
+			checks: []check{{
+				Name: "default",
+				Metric: metric{
+					Namespace:  "Namespace",
+					Name:       "Name",
+					Dimensions: []dimension{},
+				},
+				Targets: []target{{
+					Kind:      kindDeployment,
+					Mode:      modeAllOfThem,
+					Namespace: "Namespace",
+					Name:      "Name",
+				}},
+			}},
+		})
+		if err != nil {
+			t.Errorf("Unexpected failure: %v", err)
+		}
+	})
+}
+
+// TestExecuteRoundsFailures tests that executeRounds keeps going after failed
+// rounds until the configured number of consecutive failures is reached.
+func TestExecuteRoundsFailures(t *testing.T) {
+	newOptions := func(
+		ctx context.Context,
+		cwClient *cwPutMetricDataImpl,
+	) *executeRoundsOptions {
+		return &executeRoundsOptions{
+			ctx:               ctx,
+			log:               newLogger(t),
+			kClient:           kubefake.NewSimpleClientset(),
+			sinks:             newTestSinks(cwClient, retry{Attempts: 1}),
+			exitAfterFailures: 2,
+			seconds:           1,
+			checks: []check{{
+				Name: "default",
+				Metric: metric{
+					Namespace:  "Namespace",
+					Name:       "Name",
+					Dimensions: []dimension{},
+				},
+				Targets: []target{{
+					Kind:      kindDeployment,
+					Mode:      modeAllOfThem,
+					Namespace: "Namespace",
+					Name:      "Name",
+				}},
+			}},
+		}
+	}
+
+	t.Run("Recover", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		calls := make(chan *cw.PutMetricDataInput)
+		cwClient := &cwPutMetricDataImpl{failFirst: 1, calls: calls}
+
+		done := make(chan error)
+
+		go func() {
+			done <- executeRounds(newOptions(ctx, cwClient))
+		}()
+
+		select {
+		case input := <-calls:
+			datum := input.MetricData[len(input.MetricData)-1]
+			if *datum.MetricName != metricNameFailedUpdates ||
+				*datum.Value != 1 {
+				t.Errorf(
+					"Unexpected last datum: %v=%v",
+					*datum.MetricName, *datum.Value,
+				)
+			}
+		case err := <-done:
+			t.Fatalf("Unexpected stop: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for metric to be published")
+		}
+
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Errorf("Unexpected failure: %v", err)
+		}
+	})
+
+	t.Run("Buffered", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		datums, err := newDatumBuffer(newLogger(t), 100, "")
+		if err != nil {
+			t.Fatalf("Failed to create buffer: %v", err)
+		}
+
+		// The outage lasts longer than the allowed consecutive failures.
+		calls := make(chan *cw.PutMetricDataInput)
+		cwClient := &cwPutMetricDataImpl{failFirst: 3, calls: calls}
+
+		options := newOptions(ctx, cwClient)
+		options.sinks[0].sink = &metricSink{
+			log:    newLogger(t),
+			client: cwClient,
+			retry:  retry{Attempts: 1},
+			buffer: datums,
+		}
+
+		done := make(chan error)
+
+		go func() {
+			done <- executeRounds(options)
+		}()
+
+		// Waits for the next call and returns the number of sent datums.
+		nextCount := func() int {
+			t.Helper()
+
+			select {
+			case input := <-calls:
+				return len(input.MetricData)
+			case err := <-done:
+				t.Fatalf("Unexpected stop: %v", err)
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Timed out waiting for metric to be published")
+			}
+
+			return 0
+		}
+
+		// Current datum and the number of failed updates.
+		if count := nextCount(); count != 2 {
+			t.Errorf("Unexpected datums: got %v, want 2", count)
+		}
+
+		// Buffered datums of the three failed rounds. All but the first
+		// include the number of failed updates.
+		if count := nextCount(); count != 5 {
+			t.Errorf("Unexpected buffered datums: got %v, want 5", count)
+		}
+
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Errorf("Unexpected failure: %v", err)
+		}
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		cwClient := &cwPutMetricDataImpl{returnError: true}
+
+		err := executeRounds(newOptions(t.Context(), cwClient))
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "give up after 2 consecutive failures"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+}
+
+// sinkImpl is a fake sink that records what it publishes.
+type sinkImpl struct {
+	returnError bool        // Should publishing fail?
+	published   [][]scan    // Published scans.
+	shutdowns   []float64   // Published shutdown values.
+	calls       chan []scan // Optional. Receives published scans.
+}
+
+// publish implements sink.
+func (s *sinkImpl) publish(_ context.Context, _ []check, scans []scan) error {
+	s.published = append(s.published, scans)
+
+	if s.calls != nil {
+		s.calls <- scans
+	}
+
+	if s.returnError {
+		return fmt.Errorf("fake error")
+	}
+
+	return nil
+}
+
+// publishShutdown implements sink.
+func (s *sinkImpl) publishShutdown(
+	_ context.Context,
+	_ []check,
+	value float64,
+) error {
+	s.shutdowns = append(s.shutdowns, value)
+
+	if s.returnError {
+		return fmt.Errorf("fake error")
+	}
+
+	return nil
+}
+
+// TestExecuteRoundsSinks tests that executeRounds publishes with all sinks
+// and respects their failure policies.
+func TestExecuteRoundsSinks(t *testing.T) {
+	newOptions := func(
+		ctx context.Context,
+		sinks []outputSink,
+	) *executeRoundsOptions {
+		return &executeRoundsOptions{
+			ctx:      ctx,
+			log:      newLogger(t),
+			kClient:  kubefake.NewSimpleClientset(),
+			sinks:    sinks,
+			shutdown: shutdown{Publish: true, Value: -1},
+			single:   true,
+			seconds:  1,
+			checks: []check{{
+				Name:   "default",
+				Metric: metric{Namespace: "Namespace", Name: "Name"},
+				Targets: []target{{
+					Kind:      kindDeployment,
+					Mode:      modeAllOfThem,
+					Namespace: "Namespace",
+					Name:      "Name",
+				}},
+			}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string // Name of test case.
+		fatal     bool   // Should the failing sink be fatal?
+		wantError string // Substring expected to be in error string.
+	}{{
+		name:      "BestEffortFailure",
+		fatal:     false,
+		wantError: "",
+	}, {
+		name:      "FatalFailure",
+		fatal:     true,
+		wantError: "give up after 1 consecutive failures of output Failing",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			failing := &sinkImpl{returnError: true}
+			working := &sinkImpl{returnError: false}
+
+			health := newProbes(time.Minute, time.Minute)
+			health.markVerified()
+
+			options := newOptions(t.Context(), []outputSink{
+				{name: "Failing", sink: failing, fatal: tc.fatal},
+				{name: "Working", sink: working, fatal: true},
+			})
+			options.probes = health
+
+			err := executeRounds(options)
+
+			if tc.wantError == "" && err != nil {
+				t.Fatalf("Unexpected failure: %v", err)
+			}
+
+			if tc.wantError != "" &&
+				(err == nil || !strings.Contains(err.Error(), tc.wantError)) {
+				t.Fatalf(
+					"Expected error to contain %q, got %v", tc.wantError, err,
+				)
+			}
+
+			if len(failing.published) != 1 {
+				t.Errorf(
+					"Unexpected publishes of failing sink: %v",
+					len(failing.published),
+				)
+			}
+
+			// Fatal failures stop the round before the next sink.
+			wantWorking := 1
+			if tc.fatal {
+				wantWorking = 0
+			}
+
+			if len(working.published) != wantWorking {
+				t.Errorf(
+					"Unexpected publishes of working sink: got %v, want %v",
+					len(working.published),
+					wantWorking,
+				)
+			}
+
+			// Failures of best-effort sinks do not affect readiness.
+			if ready := health.ready() == nil; ready == tc.fatal {
+				t.Errorf("Unexpected readiness: %v", ready)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name    string // Name of test case.
+		fatal   bool   // Should the failing sink be fatal?
+		wantErr bool   // Is an error expected?
+	}{{
+		name:    "ShutdownBestEffortFailure",
+		fatal:   false,
+		wantErr: false,
+	}, {
+		name:    "ShutdownFatalFailure",
+		fatal:   true,
+		wantErr: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			cancel()
+
+			failing := &sinkImpl{returnError: true}
+			working := &sinkImpl{returnError: false}
+
+			err := executeRounds(newOptions(ctx, []outputSink{
+				{name: "Failing", sink: failing, fatal: tc.fatal},
+				{name: "Working", sink: working, fatal: true},
+			}))
+
+			if tc.wantErr != (err != nil) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			// All sinks get the shutdown value, regardless of failures.
+			for _, sink := range []*sinkImpl{failing, working} {
+				if diff := cmp.Diff([]float64{-1}, sink.shutdowns); diff != "" {
+					t.Errorf("Shutdowns mismatch (-want +got):\n%v", diff)
+				}
+			}
+		})
+	}
+}
+
+// TestNewSinks tests that newSinks creates one sink per output with the
+// configured failure policy.
+func TestNewSinks(t *testing.T) {
+	t.Run("EMF", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metrics.log")
+
+		sinks, closeSinks, err := newSinks(
+			t.Context(),
+			newLogger(t),
+			config{Outputs: []output{{
+				Type:          outputTypeEMF,
+				Path:          path,
+				FailurePolicy: failurePolicyBestEffort,
+			}}},
+			nil,
+		)
+		if err != nil {
+			t.Fatalf("Failed to create sinks: %v", err)
+		}
+
+		if len(sinks) != 1 || sinks[0].name != outputTypeEMF || sinks[0].fatal {
+			t.Fatalf("Unexpected sinks: %+v", sinks)
+		}
+
+		err = sinks[0].sink.publish(
+			t.Context(),
+			[]check{{Metric: metric{Namespace: "A", Name: "B"}}},
+			[]scan{{ready: true}},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		closeSinks()
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+
+		if !strings.Contains(string(content), `"B":1`) {
+			t.Errorf("Unexpected content: %s", content)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		_, _, err := newSinks(
+			t.Context(),
+			newLogger(t),
+			config{Outputs: []output{{
+				Type: outputTypeEMF,
+				Path: filepath.Join(t.TempDir(), "missing", "metrics.log"),
+			}}},
+			nil,
+		)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "outputs[0]: create EMF client"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+}
+
+// TestExecuteRoundsShutdown tests that executeRounds finishes the round in
+// flight when the context is canceled and then publishes the shutdown value
+// or skips publishing, depending on the configuration.
+func TestExecuteRoundsShutdown(t *testing.T) {
+	for _, tc := range []struct {
+		name      string    // Name of test case.
+		shutdown  shutdown  // Shutdown configuration.
+		expValues []float64 // Expected published aggregate values.
+	}{{
+		name:      "Skip",
+		shutdown:  shutdown{Publish: false, Value: -1},
+		expValues: []float64{1},
+	}, {
+		name:      "Publish",
+		shutdown:  shutdown{Publish: true, Value: -1},
+		expValues: []float64{1, -1},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			kubeClient := kubefake.NewSimpleClientset(
+				newDeployment(t, "Foo", "x", nil, 1, 1),
+			)
+
+			// Simulates a shutdown signal while the round is in flight.
+			kubeClient.PrependReactor(
+				"get", "deployments",
+				func(kubetesting.Action) (bool, kuberuntime.Object, error) {
+					cancel()
+
+					return false, nil, nil
+				},
+			)
+
+			cwClient := &cwPutMetricDataImpl{}
+
+			err := executeRounds(&executeRoundsOptions{
+				ctx:      ctx,
+				log:      newLogger(t),
+				kClient:  kubeClient,
+				sinks:    newTestSinks(cwClient, retry{}),
+				shutdown: tc.shutdown,
+				single:   false,
+				seconds:  1,
+				checks: []check{{
+					Name: "default",
+					Metric: metric{
+						Namespace:  "Namespace",
+						Name:       "Name",
+						Dimensions: []dimension{},
+					},
+					Targets: []target{{
+						Kind:      kindDeployment,
+						Namespace: "Foo",
+						Name:      "x",
+						Mode:      modeAllOfThem,
+					}},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected failure: %v", err)
+			}
+
+			values := []float64{}
+			for _, input := range cwClient.inputs {
+				values = append(values, *input.MetricData[0].Value)
+			}
+
+			if diff := cmp.Diff(tc.expValues, values); diff != "" {
+				t.Errorf("Values mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// TestExecuteRoundsShutdownTimeout tests that executeRounds stops retrying
+// once the shutdown timeout has passed after the context is canceled, both in
+// the round in flight and when publishing the shutdown value.
+func TestExecuteRoundsShutdownTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name     string   // Name of test case.
+		shutdown shutdown // Shutdown configuration.
+		expError bool     // Is an error expected?
+	}{{
+		name:     "Round",
+		shutdown: shutdown{Publish: false, Value: -1},
+		expError: false,
+	}, {
+		name:     "Shutdown",
+		shutdown: shutdown{Publish: true, Value: -1},
+		expError: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			kubeClient := kubefake.NewSimpleClientset(
+				newDeployment(t, "Foo", "x", nil, 1, 1),
+			)
+
+			// Simulates a shutdown signal while the round is in flight.
+			kubeClient.PrependReactor(
+				"get", "deployments",
+				func(kubetesting.Action) (bool, kuberuntime.Object, error) {
+					cancel()
+
+					return false, nil, nil
+				},
+			)
+
+			// Without the shutdown timeout, the backoff would block for hours.
+			sinks := newTestSinks(
+				&cwPutMetricDataImpl{returnError: true},
+				retry{
+					Attempts:       10,
+					InitialBackoff: time.Hour,
+					MaxBackoff:     time.Hour,
+				},
+			)
+
+			done := make(chan error)
+
+			go func() {
+				done <- executeRounds(&executeRoundsOptions{
+					ctx:               ctx,
+					log:               newLogger(t),
+					kClient:           kubeClient,
+					sinks:             sinks,
+					shutdown:          tc.shutdown,
+					shutdownTimeout:   100 * time.Millisecond,
+					exitAfterFailures: 2,
+					seconds:           1,
+					checks: []check{{
+						Name:   "default",
+						Metric: metric{Namespace: "Namespace", Name: "Name"},
+						Targets: []target{{
+							Kind:      kindDeployment,
+							Namespace: "Foo",
+							Name:      "x",
+							Mode:      modeAllOfThem,
+						}},
+					}},
+				})
+			}()
+
+			select {
+			case err := <-done:
+				if tc.expError != (err != nil) {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for rounds to stop")
+			}
+		})
+	}
+}
+
+// TestExecuteRoundsPublishOnChange tests that executeRounds publishes the
+// metric as soon as the aggregate status in the informer cache changes.
+func TestExecuteRoundsPublishOnChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	kubeClient := kubefake.NewSimpleClientset(
+		newDeployment(t, "Foo", "x", nil, 2, 2),
+	)
+	dynamicClient := kubedynamicfake.NewSimpleDynamicClient(
+		kuberuntime.NewScheme(),
+	)
+
+	targets := []target{{
+		Kind:      kindDeployment,
+		Namespace: "Foo",
+		Name:      "x",
+		Mode:      modeAllOfThem,
+	}}
+
+	cache, err := newInformerCache(ctx, kubeClient, dynamicClient, targets)
+	if err != nil {
+		t.Fatalf("Failed to create informer cache: %v", err)
+	}
+
+	calls := make(chan *cw.PutMetricDataInput)
+	done := make(chan error)
+
+	go func() {
+		done <- executeRounds(&executeRoundsOptions{
+			ctx:     ctx,
+			log:     newLogger(t),
+			kClient: kubeClient,
+			dClient: dynamicClient,
+			sinks: newTestSinks(
+				&cwPutMetricDataImpl{calls: calls}, retry{},
+			),
+			cache:           cache,
+			publishOnChange: true,
+			single:          false,
+			seconds:         3600,
+			checks: []check{{
+				Name: "default",
+				Metric: metric{
+					Namespace:  "Namespace",
+					Name:       "Name",
+					Dimensions: []dimension{},
+				},
+				Targets: targets,
+			}},
+		})
+	}()
+
+	// Waits for the next call and returns the published aggregate value.
+	nextValue := func() float64 {
+		t.Helper()
+
+		select {
+		case input := <-calls:
+			return *input.MetricData[0].Value
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for metric to be published")
+		}
+
+		return 0
+	}
+
+	updateDeployment := func(replicas, readyReplicas int32) {
+		t.Helper()
+
+		_, err := kubeClient.AppsV1().Deployments("Foo").Update(
+			t.Context(),
+			newDeployment(t, "Foo", "x", nil, replicas, readyReplicas),
+			kubemetav1.UpdateOptions{},
+		)
+		if err != nil {
+			t.Fatalf("Failed to update deployment: %v", err)
+		}
+	}
+
+	if value := nextValue(); value != 1 {
+		t.Errorf("Unexpected initial value: got %v, want 1", value)
+	}
+
+	// Unchanged aggregate status must not be published.
+	updateDeployment(3, 3)
+
+	// Changed aggregate status must be published right away.
+	updateDeployment(3, 1)
+
+	if value := nextValue(); value != 0 {
+		t.Errorf("Unexpected value after change: got %v, want 0", value)
+	}
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected failure: %v", err)
+	}
+}
+
+// TestExecuteRoundsPublishOnChangeFailures tests that failed publishes
+// triggered by changes do not count towards giving up, as only tick rounds
+// do.
+func TestExecuteRoundsPublishOnChangeFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	kubeClient := kubefake.NewSimpleClientset(
+		newDeployment(t, "Foo", "x", nil, 2, 2),
+	)
+	dynamicClient := kubedynamicfake.NewSimpleDynamicClient(
+		kuberuntime.NewScheme(),
+	)
+
+	targets := []target{{
+		Kind:      kindDeployment,
+		Namespace: "Foo",
+		Name:      "x",
+		Mode:      modeAllOfThem,
+	}}
+
+	cache, err := newInformerCache(ctx, kubeClient, dynamicClient, targets)
+	if err != nil {
+		t.Fatalf("Failed to create informer cache: %v", err)
+	}
+
+	// Buffered, so that a publish still in flight on shutdown does not block.
+	calls := make(chan []scan, 3)
+	done := make(chan error)
+
+	go func() {
+		done <- executeRounds(&executeRoundsOptions{
+			ctx:     ctx,
+			log:     newLogger(t),
+			kClient: kubeClient,
+			dClient: dynamicClient,
+			sinks: []outputSink{{
+				name:  outputTypeCloudWatch,
+				sink:  &sinkImpl{returnError: true, calls: calls},
+				fatal: true,
+			}},
+			cache:             cache,
+			publishOnChange:   true,
+			exitAfterFailures: 1,
+			seconds:           3600,
+			checks: []check{{
+				Name:    "default",
+				Metric:  metric{Namespace: "Namespace", Name: "Name"},
+				Targets: targets,
+			}},
+		})
+	}()
+
+	// Every change of a flapping workload is published again, as the last
+	// publish failed.
+	for _, readyReplicas := range []int32{0, 2, 0} {
+		select {
+		case <-calls:
+		case err := <-done:
+			t.Fatalf("Unexpected stop: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for publish")
+		}
+
+		_, err := kubeClient.AppsV1().Deployments("Foo").Update(
+			t.Context(),
+			newDeployment(t, "Foo", "x", nil, 2, readyReplicas),
+			kubemetav1.UpdateOptions{},
+		)
+		if err != nil {
+			t.Fatalf("Failed to update deployment: %v", err)
+		}
+	}
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected failure: %v", err)
+	}
+}
+
+// TestExporter tests that the exporter exposes scans, ticks, and
+// PutMetricData calls as Prometheus metrics.
+func TestExporter(t *testing.T) {
+	checks := []check{{Name: "a"}, {Name: "b"}}
+
+	newResult := func(name string, success, ready bool, count int) result {
+		return result{
+			success:   success,
+			ready:     ready,
+			kind:      kindDeployment,
+			namespace: "Foo",
+			name:      name,
+			replicas:  replicas{ready: count, desired: 3},
+		}
+	}
+
+	t.Run("Scans", func(t *testing.T) {
+		prom := newExporter()
+
+		prom.observeScans(checks, []scan{{
+			ready: false,
+			results: []result{
+				newResult("x", true, true, 3),
+				newResult("y", false, false, 0),
+			},
+			duration: 100 * time.Millisecond,
+		}, {
+			ready:   true,
+			results: []result{newResult("z", true, false, 1)},
+		}})
+
+		// Series of targets that disappeared must be removed.
+		prom.observeScans(checks[:1], []scan{{
+			ready:   true,
+			results: []result{newResult("x", true, true, 3)},
+		}})
+
+		want := `
+			# HELP kubestatus2cloudwatch_check_ready Aggregate status of the check, 1 if ready.
+			# TYPE kubestatus2cloudwatch_check_ready gauge
+			kubestatus2cloudwatch_check_ready{check="a"} 1
+			kubestatus2cloudwatch_check_ready{check="b"} 1
+			# HELP kubestatus2cloudwatch_target_desired_replicas Number of desired replicas of the target.
+			# TYPE kubestatus2cloudwatch_target_desired_replicas gauge
+			kubestatus2cloudwatch_target_desired_replicas{check="a",kind="Deployment",name="x",namespace="Foo"} 3
+			kubestatus2cloudwatch_target_desired_replicas{check="b",kind="Deployment",name="z",namespace="Foo"} 3
+			# HELP kubestatus2cloudwatch_target_ready Status of the target, 1 if ready.
+			# TYPE kubestatus2cloudwatch_target_ready gauge
+			kubestatus2cloudwatch_target_ready{check="a",kind="Deployment",name="x",namespace="Foo"} 1
+			kubestatus2cloudwatch_target_ready{check="b",kind="Deployment",name="z",namespace="Foo"} 0
+			# HELP kubestatus2cloudwatch_target_ready_replicas Number of ready replicas of the target.
+			# TYPE kubestatus2cloudwatch_target_ready_replicas gauge
+			kubestatus2cloudwatch_target_ready_replicas{check="a",kind="Deployment",name="x",namespace="Foo"} 3
+			kubestatus2cloudwatch_target_ready_replicas{check="b",kind="Deployment",name="z",namespace="Foo"} 1
+		`
+
+		if err := promtestutil.GatherAndCompare(
+			prom.registry,
+			strings.NewReader(want),
+			"kubestatus2cloudwatch_check_ready",
+			"kubestatus2cloudwatch_target_desired_replicas",
+			"kubestatus2cloudwatch_target_ready",
+			"kubestatus2cloudwatch_target_ready_replicas",
+		); err != nil {
+			t.Error(err)
+		}
+
+		if count := promtestutil.CollectAndCount(
+			prom.scanDuration,
+		); count != 2 {
+			t.Errorf("Unexpected scan duration series: got %v, want 2", count)
+		}
+	})
+
+	t.Run("Scrapes", func(t *testing.T) {
+		prom := newExporter()
+		scans := []scan{{results: []result{newResult("x", true, true, 3)}}}
+
+		prom.observeScans(checks[:1], scans)
+		gauge := prom.readyReplicas.WithLabelValues(
+			"a", kindDeployment, "Foo", "x",
+		)
+
+		// Current series must be updated in place instead of being deleted
+		// and set again, so that scrapes in between do not miss them.
+		prom.observeScans(checks[:1], scans)
+		gauge.Set(42)
+
+		if value := promtestutil.ToFloat64(
+			prom.readyReplicas.WithLabelValues("a", kindDeployment, "Foo", "x"),
+		); value != 42 {
+			t.Errorf("Series was replaced: got %v, want 42", value)
+		}
+
+		// Counts of targets that cannot be queried anymore are stale.
+		prom.observeScans(checks[:1], []scan{{
+			results: []result{newResult("x", false, false, 0)},
+		}})
+
+		if count := promtestutil.CollectAndCount(
+			prom.readyReplicas,
+		); count != 0 {
+			t.Errorf("Unexpected replica series: got %v, want 0", count)
+		}
+
+		if count := promtestutil.CollectAndCount(prom.targetReady); count != 1 {
+			t.Errorf("Unexpected target series: got %v, want 1", count)
+		}
+	})
+
+	t.Run("Ticks", func(t *testing.T) {
+		prom := newExporter()
+
+		prom.observeTick()
+		prom.observeTick()
+
+		if value := promtestutil.ToFloat64(prom.ticks); value != 2 {
+			t.Errorf("Unexpected tick count: got %v, want 2", value)
+		}
+	})
+
+	t.Run("PutMetricData", func(t *testing.T) {
+		prom := newExporter()
+		client := prom.instrument(
+			outputTypeCloudWatch, &cwPutMetricDataImpl{failFirst: 1},
+		)
+		emf := prom.instrument(outputTypeEMF, &cwPutMetricDataImpl{})
+
+		for range 3 {
+			_, _ = client.PutMetricData(t.Context(), &cw.PutMetricDataInput{})
+		}
+
+		_, _ = emf.PutMetricData(t.Context(), &cw.PutMetricDataInput{})
+
+		want := `
+			# HELP kubestatus2cloudwatch_put_metric_data_total Number of PutMetricData calls by output and outcome.
+			# TYPE kubestatus2cloudwatch_put_metric_data_total counter
+			kubestatus2cloudwatch_put_metric_data_total{outcome="failure",output="CloudWatch"} 1
+			kubestatus2cloudwatch_put_metric_data_total{outcome="success",output="CloudWatch"} 2
+			kubestatus2cloudwatch_put_metric_data_total{outcome="failure",output="EMF"} 0
+			kubestatus2cloudwatch_put_metric_data_total{outcome="success",output="EMF"} 1
+		`
+
+		if err := promtestutil.GatherAndCompare(
+			prom.registry,
+			strings.NewReader(want),
+			"kubestatus2cloudwatch_put_metric_data_total",
+		); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var prom *exporter
+
+		prom.observeTick()
+		prom.observeScans(checks[:1], []scan{{ready: true}})
+
+		client := &cwPutMetricDataImpl{}
+		if prom.instrument(outputTypeCloudWatch, client) != client {
+			t.Errorf("Expected client to be returned as is")
+		}
+	})
+}
+
+// TestServer tests that the server exposes the metrics of the exporter and
+// the probes, and that startServer fails right away if it cannot listen.
+func TestServer(t *testing.T) {
+	get := func(server *http.Server, path string) (int, string) {
+		t.Helper()
+
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(
+			recorder,
+			httptest.NewRequestWithContext(
+				t.Context(), http.MethodGet, path, nil,
+			),
+		)
+
+		return recorder.Code, recorder.Body.String()
+	}
+
+	t.Run("Metrics", func(t *testing.T) {
+		prom := newExporter()
+		prom.observeTick()
+
+		code, body := get(newServer(":0", prom, nil), "/metrics")
+		if code != http.StatusOK {
+			t.Fatalf("Unexpected status code: %v", code)
+		}
+
+		want := "kubestatus2cloudwatch_ticks_total 1"
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected body to contain %q", want)
+		}
+	})
+
+	t.Run("Readiness", func(t *testing.T) {
+		health := newProbes(time.Minute, time.Minute)
+		server := newServer(":0", newExporter(), health)
+
+		for _, step := range []struct {
+			mark     func() // Marks applied before the request.
+			wantCode int    // Expected status code.
+			wantBody string // Substring expected to be in body.
+		}{{
+			mark:     func() {},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "clients not verified yet",
+		}, {
+			mark:     health.markVerified,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "no successful round yet",
+		}, {
+			mark:     func() { health.markRound(false) },
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "no successful round yet",
+		}, {
+			mark:     func() { health.markRound(true) },
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		}, {
+			// Once ready, later failed rounds do not change readiness.
+			mark:     func() { health.markRound(false) },
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		}} {
+			step.mark()
+
+			code, body := get(server, "/readyz")
+			if code != step.wantCode || !strings.Contains(body, step.wantBody) {
+				t.Errorf(
+					"Unexpected response: got %v %q, want %v %q",
+					code, body, step.wantCode, step.wantBody,
+				)
+			}
+		}
+	})
+
+	t.Run("Liveness", func(t *testing.T) {
+		// Single round allowed to be overdue, the worst case allowed by the
+		// configuration.
+		health := newProbes(50*time.Millisecond, 50*time.Millisecond)
+		server := newServer(":0", newExporter(), health)
+
+		// Startup, like syncing the cache, may take longer than the interval.
+		time.Sleep(150 * time.Millisecond)
+
+		if code, body := get(server, "/healthz"); code != http.StatusOK {
+			t.Errorf("Unexpected response before start: %v %q", code, body)
+		}
+
+		health.markStarted()
+
+		// The first round is not due yet.
+		if code, body := get(server, "/healthz"); code != http.StatusOK {
+			t.Errorf("Unexpected response after start: %v %q", code, body)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+
+		code, body := get(server, "/healthz")
+		if code != http.StatusServiceUnavailable ||
+			!strings.Contains(body, "round overdue") {
+			t.Errorf("Unexpected response without rounds: %v %q", code, body)
+		}
 
-		err := executeRounds(&executeRoundsOptions{
-			ctx:      ctx,
-			log:      log,
-			dry:      false,
-			kClient:  kubefake.NewSimpleClientset(),
-			cwClient: &cwPutMetricDataImpl{false},
-			single:   false,
-			seconds:  1,
-			metric: metric{
-				Namespace:  "Namespace",
-				Name:       "Name",
-				Dimensions: []dimension{},
-			},
-			targets: []target{{
-				Kind:      kindDeployment,
-				Mode:      modeAllOfThem,
-				Namespace: "Namespace",
-				Name:      "Name",
+		health.markRound(false)
+
+		if code, body := get(server, "/healthz"); code != http.StatusOK {
+			t.Errorf("Unexpected response after round: %v %q", code, body)
+		}
+	})
+
+	t.Run("StartStop", func(t *testing.T) {
+		stop, err := startServer(
+			t.Context(),
+			newLogger(t),
+			newServer("127.0.0.1:0", newExporter(), nil),
+		)
+		if err != nil {
+			t.Fatalf("Failed to start server: %v", err)
+		}
+
+		stop()
+	})
+
+	t.Run("AddressInUse", func(t *testing.T) {
+		listener, err := (&net.ListenConfig{}).Listen(
+			t.Context(), "tcp", "127.0.0.1:0",
+		)
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer listener.Close()
+
+		_, err = startServer(
+			t.Context(),
+			newLogger(t),
+			newServer(listener.Addr().String(), newExporter(), nil),
+		)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		if !strings.Contains(err.Error(), "listen") {
+			t.Errorf("Expected error to contain %q, got %q", "listen", err)
+		}
+	})
+}
+
+// failingWriter is a writer that always fails.
+type failingWriter struct{}
+
+// Write implements io.Writer and always returns an error.
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("fake error")
+}
+
+// TestEMFClient tests that the EMF client writes every datum as a single EMF
+// line to the configured writer.
+func TestEMFClient(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Lines", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		client := &emfClient{writer: &buf}
+
+		err := updateMetric(&updateMetricOptions{
+			ctx:    t.Context(),
+			log:    newLogger(t),
+			client: client,
+			updates: []metricUpdate{{
+				metric: metric{
+					Namespace: "MyNamespace",
+					Name:      "MyMetric",
+					Dimensions: []dimension{
+						{Name: "Cluster", Value: "MyCluster"},
+					},
+					StorageResolution: storageResolutionHigh,
+				},
+				value:     1,
+				timestamp: timestamp,
+				failures:  2,
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		want := []string{
+			`{"Cluster":"MyCluster","MyMetric":1,` +
+				`"_aws":{"Timestamp":1704164645000,` +
+				`"CloudWatchMetrics":[{"Namespace":"MyNamespace",` +
+				`"Dimensions":[["Cluster"]],` +
+				`"Metrics":[{"Name":"MyMetric",` +
+				`"Unit":"None","StorageResolution":1}]}]}}`,
+			`{"Cluster":"MyCluster","FailedUpdates":2,` +
+				`"_aws":{"Timestamp":1704164645000,` +
+				`"CloudWatchMetrics":[{"Namespace":"MyNamespace",` +
+				`"Dimensions":[["Cluster"]],` +
+				`"Metrics":[{"Name":"FailedUpdates",` +
+				`"Unit":"Count","StorageResolution":1}]}]}}`,
+		}
+
+		got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Lines mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("WithoutDimensionsAndTimestamp", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		client := &emfClient{writer: &buf}
+
+		before := time.Now().UnixMilli()
+
+		_, err := client.PutMetricData(t.Context(), &cw.PutMetricDataInput{
+			Namespace: aws.String("MyNamespace"),
+			MetricData: []cwtypes.MetricDatum{{
+				MetricName: aws.String("MyMetric"),
+				Value:      aws.Float64(0),
 			}},
 		})
 		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+
+		var line struct {
+			MyMetric float64     `json:"MyMetric"`
+			AWS      emfMetadata `json:"_aws"`
+		}
+
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to unmarshal line: %v", err)
+		}
+
+		if line.AWS.Timestamp < before {
+			t.Errorf("Unexpected timestamp: %v", line.AWS.Timestamp)
+		}
+
+		wantDirectives := []emfDirective{{
+			Namespace:  "MyNamespace",
+			Dimensions: [][]string{{}},
+			Metrics:    []emfMetric{{Name: "MyMetric"}},
+		}}
+
+		if diff := cmp.Diff(
+			wantDirectives, line.AWS.CloudWatchMetrics,
+		); diff != "" {
+			t.Errorf("Directives mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metrics.log")
+
+		for range 2 {
+			client, err := newEMFClient(path)
+			if err != nil {
+				t.Fatalf("Failed to create EMF client: %v", err)
+			}
+
+			_, err = client.PutMetricData(t.Context(), &cw.PutMetricDataInput{
+				Namespace: aws.String("MyNamespace"),
+				MetricData: []cwtypes.MetricDatum{{
+					MetricName: aws.String("MyMetric"),
+					Value:      aws.Float64(1),
+				}},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected failure: %v", err)
+			}
+
+			if err := client.close(); err != nil {
+				t.Fatalf("Failed to close EMF client: %v", err)
+			}
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+
+		// Lines must be appended instead of overwritten.
+		if count := bytes.Count(content, []byte("\n")); count != 2 {
+			t.Errorf("Unexpected number of lines: got %v, want 2", count)
+		}
+	})
+
+	t.Run("Stdout", func(t *testing.T) {
+		client, err := newEMFClient("")
+		if err != nil {
+			t.Fatalf("Failed to create EMF client: %v", err)
+		}
+
+		if client.writer != os.Stdout {
+			t.Errorf("Expected writer to be stdout")
+		}
+
+		if err := client.close(); err != nil {
 			t.Errorf("Unexpected failure: %v", err)
 		}
 	})
+
+	t.Run("OpenFailure", func(t *testing.T) {
+		_, err := newEMFClient(filepath.Join(t.TempDir(), "missing", "x.log"))
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		if !strings.Contains(err.Error(), "open EMF file") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("WriteFailure", func(t *testing.T) {
+		client := &emfClient{writer: failingWriter{}}
+
+		_, err := client.PutMetricData(t.Context(), &cw.PutMetricDataInput{
+			Namespace: aws.String("MyNamespace"),
+			MetricData: []cwtypes.MetricDatum{{
+				MetricName: aws.String("MyMetric"),
+				Value:      aws.Float64(1),
+			}},
+		})
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		if !strings.Contains(err.Error(), "write EMF lines") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+// otlpCollector is an in-process stand-in for an OpenTelemetry collector. It
+// records received export requests or fails them if err is set.
+type otlpCollector struct {
+	collectormetricspb.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	err      error
+	requests []*collectormetricspb.ExportMetricsServiceRequest
+}
+
+// Export implements the OTLP metrics service.
+func (c *otlpCollector) Export(
+	_ context.Context,
+	request *collectormetricspb.ExportMetricsServiceRequest,
+) (*collectormetricspb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.requests = append(c.requests, request)
+
+	return &collectormetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// received returns the resource metrics of all recorded requests.
+func (c *otlpCollector) received() []*metricspb.ResourceMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics := []*metricspb.ResourceMetrics{}
+	for _, request := range c.requests {
+		metrics = append(metrics, request.GetResourceMetrics()...)
+	}
+
+	return metrics
+}
+
+// startGRPCCollector serves the given collector via OTLP/gRPC and returns the
+// endpoint URL.
+func startGRPCCollector(t *testing.T, collector *otlpCollector) string {
+	t.Helper()
+
+	listener, err := (&net.ListenConfig{}).Listen(
+		t.Context(), "tcp", "127.0.0.1:0",
+	)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := grpc.NewServer()
+	collectormetricspb.RegisterMetricsServiceServer(server, collector)
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(server.Stop)
+
+	return "http://" + listener.Addr().String()
+}
+
+// startHTTPCollector serves the given collector via OTLP/HTTP and returns the
+// endpoint URL without path.
+func startHTTPCollector(t *testing.T, collector *otlpCollector) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != otlpHTTPPath {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			request := &collectormetricspb.ExportMetricsServiceRequest{}
+			if err := proto.Unmarshal(body, request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			response, err := collector.Export(r.Context(), request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			content, err := proto.Marshal(response)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			_, _ = w.Write(content)
+		},
+	))
+
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// otlpAttributes returns the given OTLP attributes as a map.
+func otlpAttributes(attributes []*commonpb.KeyValue) map[string]string {
+	result := map[string]string{}
+	for _, attribute := range attributes {
+		result[attribute.GetKey()] = attribute.GetValue().GetStringValue()
+	}
+
+	return result
+}
+
+// otlpPoint is a simplified OTLP gauge data point used for comparisons.
+type otlpPoint struct {
+	Attributes map[string]string
+	Time       int64
+	Value      float64
+}
+
+// otlpPoints returns the data points of the only gauge of the given resource
+// metrics together with the name of the gauge.
+func otlpPoints(
+	t *testing.T,
+	metrics *metricspb.ResourceMetrics,
+) (string, []otlpPoint) {
+	t.Helper()
+
+	if len(metrics.GetScopeMetrics()) != 1 ||
+		len(metrics.GetScopeMetrics()[0].GetMetrics()) != 1 {
+		t.Fatalf("Expected single metric, got %v", metrics)
+	}
+
+	metric := metrics.GetScopeMetrics()[0].GetMetrics()[0]
+
+	points := []otlpPoint{}
+	for _, point := range metric.GetGauge().GetDataPoints() {
+		points = append(points, otlpPoint{
+			Attributes: otlpAttributes(point.GetAttributes()),
+			Time:       int64(point.GetTimeUnixNano()),
+			Value:      point.GetAsDouble(),
+		})
+	}
+
+	return metric.GetName(), points
+}
+
+// TestOTLPSink tests the OTLP output against in-process collector stand-ins
+// for both supported protocols.
+func TestOTLPSink(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	checks := []check{{
+		Name: "default",
+		Metric: metric{
+			Namespace: "MyNamespace",
+			Name:      "MyMetric",
+			Dimensions: []dimension{
+				{Name: "Cluster", Value: "MyCluster"},
+			},
+		},
+	}}
+
+	scans := []scan{{
+		ready: false,
+		start: start,
+		results: []result{{
+			success:   true,
+			ready:     true,
+			kind:      kindDeployment,
+			namespace: "default",
+			name:      "app",
+		}, {
+			success: true,
+			ready:   false,
+			kind:    kindNode,
+			name:    "node",
+		}},
+	}}
+
+	for _, tc := range []struct {
+		name     string                                  // Name of test case.
+		protocol string                                  // OTLP protocol.
+		start    func(*testing.T, *otlpCollector) string // Starts collector.
+	}{{
+		name:     "GRPC",
+		protocol: otlpProtocolGRPC,
+		start:    startGRPCCollector,
+	}, {
+		name:     "HTTP",
+		protocol: otlpProtocolHTTP,
+		start:    startHTTPCollector,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			collector := &otlpCollector{}
+
+			sinks, closeSinks, err := newSinks(
+				t.Context(),
+				newLogger(t),
+				config{
+					Retry: retry{Attempts: 1},
+					Outputs: []output{{
+						Type:          outputTypeOTLP,
+						Endpoint:      tc.start(t, collector),
+						Protocol:      tc.protocol,
+						FailurePolicy: failurePolicyFatal,
+					}},
+				},
+				nil,
+			)
+			if err != nil {
+				t.Fatalf("Failed to create sinks: %v", err)
+			}
+
+			defer closeSinks()
+
+			if len(sinks) != 1 || sinks[0].name != outputTypeOTLP {
+				t.Fatalf("Unexpected sinks: %+v", sinks)
+			}
+
+			err = sinks[0].sink.publish(t.Context(), checks, scans)
+			if err != nil {
+				t.Fatalf("Unexpected failure: %v", err)
+			}
+
+			err = sinks[0].sink.publishShutdown(t.Context(), checks, -1)
+			if err != nil {
+				t.Fatalf("Unexpected failure: %v", err)
+			}
+
+			received := collector.received()
+			if len(received) != 2 {
+				t.Fatalf("Expected 2 resource metrics, got %v", len(received))
+			}
+
+			wantResource := map[string]string{
+				"service.name":      "kubestatus2cloudwatch",
+				"service.namespace": "MyNamespace",
+				"Cluster":           "MyCluster",
+			}
+
+			for _, metrics := range received {
+				gotResource := otlpAttributes(
+					metrics.GetResource().GetAttributes(),
+				)
+				if diff := cmp.Diff(wantResource, gotResource); diff != "" {
+					t.Errorf("Resource mismatch (-want +got):\n%v", diff)
+				}
+			}
+
+			name, points := otlpPoints(t, received[0])
+			if name != "MyMetric" {
+				t.Errorf("Expected metric name MyMetric, got %v", name)
+			}
+
+			wantPoints := []otlpPoint{{
+				Attributes: map[string]string{},
+				Time:       start.UnixNano(),
+				Value:      0,
+			}, {
+				Attributes: map[string]string{
+					dimensionTargetKind:      kindDeployment,
+					dimensionTargetNamespace: "default",
+					dimensionTargetName:      "app",
+				},
+				Time:  start.UnixNano(),
+				Value: 1,
+			}, {
+				Attributes: map[string]string{
+					dimensionTargetKind: kindNode,
+					dimensionTargetName: "node",
+				},
+				Time:  start.UnixNano(),
+				Value: 0,
+			}}
+
+			if diff := cmp.Diff(wantPoints, points); diff != "" {
+				t.Errorf("Points mismatch (-want +got):\n%v", diff)
+			}
+
+			_, points = otlpPoints(t, received[1])
+			if len(points) != 1 || points[0].Value != -1 ||
+				len(points[0].Attributes) != 0 {
+				t.Errorf("Unexpected shutdown points: %+v", points)
+			}
+		})
+	}
+
+	t.Run("Failure", func(t *testing.T) {
+		collector := &otlpCollector{err: errors.New("unavailable")}
+
+		sinks, closeSinks, err := newSinks(
+			t.Context(),
+			newLogger(t),
+			config{
+				Retry: retry{Attempts: 2},
+				Outputs: []output{{
+					Type:     outputTypeOTLP,
+					Endpoint: startGRPCCollector(t, collector),
+					Protocol: otlpProtocolGRPC,
+				}},
+			},
+			nil,
+		)
+		if err != nil {
+			t.Fatalf("Failed to create sinks: %v", err)
+		}
+
+		defer closeSinks()
+
+		err = sinks[0].sink.publish(t.Context(), checks, scans)
+		if err == nil {
+			t.Fatalf("Expected error, got nil")
+		}
+
+		want := "export metrics after 2 attempts"
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %q", want, err)
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		// Exporter is nil, so any export would panic.
+		sink := newOTLPSink(newLogger(t), config{DryRun: true}, nil)
+
+		err := sink.publish(t.Context(), checks, scans)
+		if err != nil {
+			t.Fatalf("Unexpected failure: %v", err)
+		}
+	})
+}
+
+// TestVerifyCustomResources tests that custom resource targets of namespaced
+// resources without namespace are rejected, and so are targets of resources
+// that are not served if informers are enabled.
+func TestVerifyCustomResources(t *testing.T) {
+	client := kubefake.NewSimpleClientset()
+	client.Resources = []*kubemetav1.APIResourceList{{
+		GroupVersion: "cert-manager.io/v1",
+		APIResources: []kubemetav1.APIResource{
+			{Name: "certificates", Namespaced: true},
+			{Name: "clusterissuers", Namespaced: false},
+		},
+	}}
+
+	for _, tc := range []struct {
+		name      string // Name of test case.
+		target    target // Target to verify.
+		informers bool   // Are informers enabled?
+		errSubstr string // Substring expected to be in error string.
+	}{{
+		name: "ClusterScoped",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Resource:   "clusterissuers",
+			Name:       "letsencrypt",
+		},
+	}, {
+		name: "NamespacedWithNamespace",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Resource:   "certificates",
+			Namespace:  "Foo",
+			Name:       "ready",
+		},
+	}, {
+		name: "NamespacedWithoutNamespace",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Resource:   "certificates",
+			Name:       "ready",
+		},
+		errSubstr: "checks[0]: missing: target[0].namespace",
+	}, {
+		name: "UnknownGroupVersion",
+		target: target{
+			APIVersion: "example.com/v1",
+			Resource:   "widgets",
+			Name:       "a",
+		},
+	}, {
+		name: "InformersUnknownGroupVersion",
+		target: target{
+			APIVersion: "example.com/v1",
+			Resource:   "widgets",
+			Namespace:  "Foo",
+			Name:       "a",
+		},
+		informers: true,
+		errSubstr: "checks[0]: target[0].resource not served",
+	}, {
+		name: "InformersUnknownResource",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Resource:   "issuers",
+			Namespace:  "Foo",
+			Name:       "a",
+		},
+		informers: true,
+		errSubstr: "checks[0]: target[0].resource not served",
+	}, {
+		name: "InformersNamespacedWithNamespace",
+		target: target{
+			APIVersion: "cert-manager.io/v1",
+			Resource:   "certificates",
+			Namespace:  "Foo",
+			Name:       "ready",
+		},
+		informers: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyCustomResources(
+				client, []check{{Targets: []target{tc.target}}}, tc.informers,
+			)
+
+			if tc.errSubstr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected failure: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.errSubstr) {
+				t.Errorf(
+					"Expected error to contain %q, got %v", tc.errSubstr, err,
+				)
+			}
+		})
+	}
 }